@@ -0,0 +1,150 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"sync"
+)
+
+// A ContextExtractor returns the fields to add to a log entry from the values
+// stored in ctx, such as a trace or user id.
+type ContextExtractor func(ctx context.Context) map[string]interface{}
+
+var extractors struct {
+	sync.RWMutex
+	fns []ContextExtractor
+}
+
+// RegisterContextExtractor adds fn to the extractors which are called for every
+// log entry made with a context, using the Context variants of the logging
+// functions or a logger returned by FromContext. When multiple extractors
+// return the same key, the last one registered wins.
+func RegisterContextExtractor(fn ContextExtractor) {
+	extractors.Lock()
+	extractors.fns = append(extractors.fns, fn)
+	extractors.Unlock()
+}
+
+// contextFields returns the fields extracted from ctx by every registered
+// extractor.
+func contextFields(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+	extractors.RLock()
+	defer extractors.RUnlock()
+	var fields map[string]interface{}
+	for _, fn := range extractors.fns {
+		for key, value := range fn(ctx) {
+			if fields == nil {
+				fields = make(map[string]interface{})
+			}
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx which carries l.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger if there
+// is none, see Default. The returned logger adds the fields extracted from ctx
+// to every log entry.
+func FromContext(ctx context.Context) Logger {
+	l, ok := ctx.Value(contextKey{}).(Logger)
+	if !ok {
+		l = Default()
+	}
+	if logger, ok := l.(*logger); ok {
+		child := *logger
+		child.ctx = ctx
+		return &child
+	}
+	return l
+}
+
+func (logger *logger) LogContext(ctx context.Context, p Priority, v ...interface{}) {
+	logger.outputContext(ctx, 1, p, sprint(v))
+}
+
+func (logger *logger) EmergencyContext(ctx context.Context, v ...interface{}) {
+	logger.outputContext(ctx, 1, EMERGENCY, sprint(v))
+}
+
+func (logger *logger) AlertContext(ctx context.Context, v ...interface{}) {
+	logger.outputContext(ctx, 1, ALERT, sprint(v))
+}
+
+func (logger *logger) CriticalContext(ctx context.Context, v ...interface{}) {
+	logger.outputContext(ctx, 1, CRITICAL, sprint(v))
+}
+
+func (logger *logger) ErrorContext(ctx context.Context, v ...interface{}) {
+	logger.outputContext(ctx, 1, ERROR, sprint(v))
+}
+
+func (logger *logger) WarningContext(ctx context.Context, v ...interface{}) {
+	logger.outputContext(ctx, 1, WARNING, sprint(v))
+}
+
+func (logger *logger) NoticeContext(ctx context.Context, v ...interface{}) {
+	logger.outputContext(ctx, 1, NOTICE, sprint(v))
+}
+
+func (logger *logger) InfoContext(ctx context.Context, v ...interface{}) {
+	logger.outputContext(ctx, 1, INFO, sprint(v))
+}
+
+func (logger *logger) DebugContext(ctx context.Context, v ...interface{}) {
+	logger.outputContext(ctx, 1, DEBUG, sprint(v))
+}
+
+func EmergencyContext(ctx context.Context, v ...interface{}) {
+	std().EmergencyContext(ctx, v...)
+}
+
+func AlertContext(ctx context.Context, v ...interface{}) {
+	std().AlertContext(ctx, v...)
+}
+
+func CriticalContext(ctx context.Context, v ...interface{}) {
+	std().CriticalContext(ctx, v...)
+}
+
+func ErrorContext(ctx context.Context, v ...interface{}) {
+	std().ErrorContext(ctx, v...)
+}
+
+func WarningContext(ctx context.Context, v ...interface{}) {
+	std().WarningContext(ctx, v...)
+}
+
+func NoticeContext(ctx context.Context, v ...interface{}) {
+	std().NoticeContext(ctx, v...)
+}
+
+func InfoContext(ctx context.Context, v ...interface{}) {
+	std().InfoContext(ctx, v...)
+}
+
+func DebugContext(ctx context.Context, v ...interface{}) {
+	std().DebugContext(ctx, v...)
+}
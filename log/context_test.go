@@ -0,0 +1,69 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+type userKey struct{}
+
+func init() {
+	RegisterContextExtractor(func(ctx context.Context) map[string]interface{} {
+		if user, ok := ctx.Value(userKey{}).(string); ok {
+			return map[string]interface{}{"user": user}
+		}
+		return nil
+	})
+}
+
+func TestLoggerContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(WriterSink(&buf, "%s %s %s\n", []string{"priority", "user", "message"}))
+	ctx := context.WithValue(context.Background(), userKey{}, "alice")
+
+	logger.InfoContext(ctx, "context")
+	logger.Info("no context")
+	logger.LogContext(ctx, NOTICE, "log context")
+	logger.WarningContext(context.Background(), "empty context")
+
+	expected := "INFO alice context\n" +
+		"INFO ??? no context\n" +
+		"NOTICE alice log context\n" +
+		"WARNING ??? empty context\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(WriterSink(&buf, "%s %s\n", []string{"user", "message"}))
+	ctx := context.WithValue(context.Background(), userKey{}, "bob")
+	ctx = NewContext(ctx, logger)
+
+	FromContext(ctx).Info("from context")
+	FromContext(ctx).With("user", "override").Info("with")
+
+	expected := "bob from context\noverride with\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	if FromContext(context.Background()) == nil {
+		t.Error("expected the default logger")
+	}
+}
@@ -15,7 +15,13 @@ package log
 // limitations under the License.
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -26,6 +32,19 @@ type Fields map[string]fieldFn
 
 type fieldFn func() interface{}
 
+// valueFn returns a fieldFn which always returns v.
+func valueFn(v interface{}) fieldFn {
+	return func() interface{} { return v }
+}
+
+func sprint(v []interface{}) fieldFn {
+	return func() interface{} { return fmt.Sprint(v...) }
+}
+
+func sprintf(format string, v []interface{}) fieldFn {
+	return func() interface{} { return fmt.Sprintf(format, v...) }
+}
+
 func (l *logger) prefixFn() interface{} {
 	return l.prefix
 }
@@ -41,8 +60,9 @@ func (l *logger) createdFn() interface{} {
 	return l.created.Format(dateFormat)
 }
 
-func (l *logger) timeFn() interface{} {
-	return time.Now().Format(dateFormat)
+// timeFn returns a fieldFn which formats t, the time of the log entry.
+func timeFn(t time.Time) fieldFn {
+	return func() interface{} { return t.Format(dateFormat) }
 }
 
 func (l *logger) elapsedFn() interface{} {
@@ -50,9 +70,81 @@ func (l *logger) elapsedFn() interface{} {
 }
 
 func (l *logger) seqFn() interface{} {
-	return atomic.AddUint64(&l.seq, 1)
+	return atomic.AddUint64(l.seq, 1)
 }
 
 func (l *logger) pidFn() interface{} {
 	return os.Getpid()
 }
+
+// caller resolves the program counter of the caller of a logging function only
+// when one of its fields is used. The caller is the first frame in pcs which is
+// not a function of the package skipPkg.
+type caller struct {
+	pcs     []uintptr
+	skipPkg string
+	once    sync.Once
+	frame   runtime.Frame
+}
+
+func (c *caller) resolve() runtime.Frame {
+	c.once.Do(func() {
+		if len(c.pcs) == 0 || c.pcs[0] == 0 {
+			return
+		}
+		frames := runtime.CallersFrames(c.pcs)
+		for {
+			frame, more := frames.Next()
+			if c.skipPkg == "" || !strings.HasPrefix(frame.Function, c.skipPkg+".") {
+				c.frame = frame
+				return
+			}
+			if !more {
+				return
+			}
+		}
+	})
+	return c.frame
+}
+
+func (c *caller) fileFn() interface{} {
+	return c.resolve().File
+}
+
+func (c *caller) lineFn() interface{} {
+	return c.resolve().Line
+}
+
+func (c *caller) funcFn() interface{} {
+	return c.resolve().Function
+}
+
+func (c *caller) callerFn() interface{} {
+	frame := c.resolve()
+	return fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+}
+
+// stackFn returns a fieldFn which formats the stack trace in pcs the first time
+// it is called.
+func stackFn(pcs []uintptr) fieldFn {
+	var (
+		once  sync.Once
+		stack string
+	)
+	return func() interface{} {
+		once.Do(func() {
+			var buf bytes.Buffer
+			frames := runtime.CallersFrames(pcs)
+			for {
+				frame, more := frames.Next()
+				fmt.Fprintf(&buf, "%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+				if !more {
+					break
+				}
+				buf.WriteByte('\n')
+			}
+			stack = buf.String()
+		})
+		return stack
+	}
+}
@@ -0,0 +1,74 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import "fmt"
+
+// Formatter is the interface that wraps the basic Format method. Format renders
+// the Fields of a log entry into the bytes a sink will write.
+type Formatter interface {
+	Format(Fields) ([]byte, error)
+}
+
+// FormatterFunc is an adapter to allow the use of ordinary functions as
+// formatters.
+type FormatterFunc func(Fields) ([]byte, error)
+
+// Format calls f(fields).
+func (f FormatterFunc) Format(fields Fields) ([]byte, error) {
+	return f(fields)
+}
+
+// entryTime replaces the "time" field, which is formatted for humans, with the
+// "full_time" field, and leaves the latter out. It is used by formatters which
+// render every field, so they include the full time of the entry once.
+func entryTime(fields Fields) Fields {
+	full, ok := fields["full_time"]
+	if !ok {
+		return fields
+	}
+	s := make(Fields, len(fields))
+	for key, fn := range fields {
+		if key != "full_time" {
+			s[key] = fn
+		}
+	}
+	s["time"] = full
+	return s
+}
+
+type printfFormatter struct {
+	format string
+	fields []string
+}
+
+func (f *printfFormatter) Format(fields Fields) ([]byte, error) {
+	vals := make([]interface{}, len(f.fields))
+	for i, field := range f.fields {
+		if fn, ok := fields[field]; ok {
+			vals[i] = fn()
+		} else {
+			vals[i] = "???"
+		}
+	}
+	return []byte(fmt.Sprintf(f.format, vals...)), nil
+}
+
+// PrintfFormatter returns a formatter which renders the named fields using a
+// fmt style format string, such as BasicFormat and BasicFields. Fields missing
+// from the log entry are rendered as "???".
+func PrintfFormatter(format string, fields []string) Formatter {
+	return &printfFormatter{format, fields}
+}
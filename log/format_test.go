@@ -0,0 +1,50 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintfFormatter(t *testing.T) {
+	f := PrintfFormatter(RichFormat, RichFields)
+	b, err := f.Format(Fields{
+		"time":     func() interface{} { return "now" },
+		"priority": func() interface{} { return WARNING },
+		"seq":      func() interface{} { return uint64(7) },
+		"message":  func() interface{} { return "hello!" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "now [  WARNING] 7 ??? - hello!\n"
+	if string(b) != expected {
+		t.Errorf("expected %q, got %q", expected, b)
+	}
+}
+
+func TestFormatSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := FormatSink(&buf, FormatterFunc(func(fields Fields) ([]byte, error) {
+		return []byte(fields["message"]().(string)), nil
+	}))
+	sink.Log(Fields{
+		"message": func() interface{} { return "hello!" },
+	})
+	if buf.String() != "hello!" {
+		t.Errorf("unexpected output. %s", buf.String())
+	}
+}
@@ -0,0 +1,202 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// registeredFilter is a filter registered using NamedFilter.
+type registeredFilter struct {
+	level *LevelVar
+	base  Priority    // priority to revert to once timer fires
+	timer *time.Timer // pending revert, if any
+	gen   int         // incremented on every change to invalidate stale timers
+}
+
+var registry = struct {
+	sync.Mutex
+	filters map[string]*registeredFilter
+}{filters: make(map[string]*registeredFilter)}
+
+// NamedFilter is like Filter, except the sink is registered under name so that
+// its priority can be inspected and changed at runtime using LevelHandler.
+// Registering a name a second time replaces the previous filter.
+func NamedFilter(name string, p Priority, s Sink) Sink {
+	level := NewLevelVar(p)
+	RegisterFilter(name, level)
+	return Filter(level, s)
+}
+
+// RegisterFilter registers level under name so that it can be inspected and
+// changed using LevelHandler, replacing any other level registered under name.
+// Registering the level already registered under name has no effect, so that a
+// pending temporary change is kept.
+func RegisterFilter(name string, level *LevelVar) {
+	registry.Lock()
+	defer registry.Unlock()
+	r, ok := registry.filters[name]
+	if ok && r.level == level {
+		return
+	}
+	if ok && r.timer != nil {
+		r.timer.Stop()
+	}
+	registry.filters[name] = &registeredFilter{level: level}
+}
+
+// UnregisterFilter removes the filter registered under name, if any.
+func UnregisterFilter(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	if r, ok := registry.filters[name]; ok && r.timer != nil {
+		r.timer.Stop()
+	}
+	delete(registry.filters, name)
+}
+
+// FilterLevel returns the level registered under name, if any.
+func FilterLevel(name string) (*LevelVar, bool) {
+	registry.Lock()
+	defer registry.Unlock()
+	r, ok := registry.filters[name]
+	if !ok {
+		return nil, false
+	}
+	return r.level, true
+}
+
+// setFilter changes the priority of the named filter. If ttl is greater than
+// zero the filter reverts to the priority it had before the first of a series
+// of temporary changes once ttl has passed.
+func setFilter(name string, p Priority, ttl time.Duration) error {
+	registry.Lock()
+	defer registry.Unlock()
+	r, ok := registry.filters[name]
+	if !ok {
+		return fmt.Errorf("log: no filter named %q", name)
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	} else {
+		r.base = r.level.Level()
+	}
+	r.timer = nil
+	r.gen++
+	r.level.Set(p)
+	if ttl > 0 {
+		gen := r.gen
+		r.timer = time.AfterFunc(ttl, func() {
+			registry.Lock()
+			defer registry.Unlock()
+			if r.gen == gen {
+				r.level.Set(r.base)
+				r.timer = nil
+			}
+		})
+	}
+	return nil
+}
+
+func filterLevels() map[string]Priority {
+	registry.Lock()
+	defer registry.Unlock()
+	levels := make(map[string]Priority, len(registry.filters))
+	for name, r := range registry.filters {
+		levels[name] = r.level.Level()
+	}
+	return levels
+}
+
+type levelHandler struct{}
+
+// levelRequest is the body of a PUT request to the LevelHandler. Priority may
+// be a name such as "DEBUG" or a number, TTL a duration such as "10m".
+type levelRequest struct {
+	Name     string    `json:"name"`
+	Priority *Priority `json:"priority"`
+	TTL      string    `json:"ttl"`
+}
+
+func (h levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "PUT":
+		if err := h.put(r); err != nil {
+			if _, ok := err.(notFoundError); ok {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filterLevels())
+}
+
+type notFoundError struct{ error }
+
+func (h levelHandler) put(r *http.Request) error {
+	var req levelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("log: invalid request body: %s", err)
+	}
+	if req.Priority == nil {
+		return fmt.Errorf("log: missing priority")
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			return fmt.Errorf("log: invalid ttl: %s", err)
+		}
+	}
+	names := []string{req.Name}
+	if req.Name == "" {
+		names = names[:0]
+		for name := range filterLevels() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		if err := setFilter(name, *req.Priority, ttl); err != nil {
+			return notFoundError{err}
+		}
+	}
+	return nil
+}
+
+// LevelHandler returns an http.Handler which reports the priority of every
+// filter registered using NamedFilter as a JSON object on GET, and changes it
+// on PUT. The body of a PUT request is a JSON object such as:
+//
+//	{"name": "stdout", "priority": "DEBUG", "ttl": "10m"}
+//
+// The priority can be a name or a number. If name is omitted every registered
+// filter is changed. If ttl is set the change is reverted once it has passed.
+func LevelHandler() http.Handler {
+	return levelHandler{}
+}
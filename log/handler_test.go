@@ -0,0 +1,116 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveLevels(t *testing.T, method, body string) (int, map[string]string) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "/log/levels", strings.NewReader(body))
+	LevelHandler().ServeHTTP(w, r)
+	levels := make(map[string]string)
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &levels); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, levels
+}
+
+func TestLevelHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(NamedFilter("handler-test", INFO, WriterSink(&buf, "%s\n", []string{"message"})))
+
+	code, levels := serveLevels(t, "GET", "")
+	if code != http.StatusOK || levels["handler-test"] != "INFO" {
+		t.Fatalf("unexpected response %d %v", code, levels)
+	}
+
+	code, levels = serveLevels(t, "PUT", `{"name": "handler-test", "priority": "debug"}`)
+	if code != http.StatusOK || levels["handler-test"] != "DEBUG" {
+		t.Fatalf("unexpected response %d %v", code, levels)
+	}
+	logger.Debug("written")
+
+	code, levels = serveLevels(t, "PUT", `{"name": "handler-test", "priority": 3}`)
+	if code != http.StatusOK || levels["handler-test"] != "ERROR" {
+		t.Fatalf("unexpected response %d %v", code, levels)
+	}
+	logger.Warning("dropped")
+
+	if buf.String() != "written\n" {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+
+	for body, expected := range map[string]int{
+		`{"name": "handler-test", "priority": "LOUD"}`: http.StatusBadRequest,
+		`{"name": "handler-test", "priority": 9}`:      http.StatusBadRequest,
+		`{"name": "missing", "priority": "INFO"}`:      http.StatusNotFound,
+		`{"name": "handler-test"}`:                     http.StatusBadRequest,
+		`not json`:                                     http.StatusBadRequest,
+	} {
+		if code, _ := serveLevels(t, "PUT", body); code != expected {
+			t.Errorf("expected %d for %s, got %d", expected, body, code)
+		}
+	}
+	if code, _ := serveLevels(t, "POST", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+}
+
+func TestLevelHandlerTTL(t *testing.T) {
+	NamedFilter("handler-ttl-test", WARNING, NilSink())
+
+	serveLevels(t, "PUT", `{"name": "handler-ttl-test", "priority": "INFO", "ttl": "1h"}`)
+	code, levels := serveLevels(t, "PUT", `{"name": "handler-ttl-test", "priority": "DEBUG", "ttl": "50ms"}`)
+	if code != http.StatusOK || levels["handler-ttl-test"] != "DEBUG" {
+		t.Fatalf("unexpected response %d %v", code, levels)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, levels = serveLevels(t, "GET", "")
+	if levels["handler-ttl-test"] != "WARNING" {
+		t.Errorf("expected priority to revert to WARNING, got %s", levels["handler-ttl-test"])
+	}
+}
+
+func TestRegisterFilter(t *testing.T) {
+	level := NewLevelVar(WARNING)
+	RegisterFilter("handler-register-test", level)
+	serveLevels(t, "PUT", `{"name": "handler-register-test", "priority": "DEBUG"}`)
+
+	// registering the same level again keeps the change.
+	RegisterFilter("handler-register-test", level)
+	if l, ok := FilterLevel("handler-register-test"); !ok || l != level || l.Level() != DEBUG {
+		t.Errorf("expected the registered level to be kept at DEBUG")
+	}
+
+	UnregisterFilter("handler-register-test")
+	if _, ok := FilterLevel("handler-register-test"); ok {
+		t.Error("expected the filter to be unregistered")
+	}
+	if code, _ := serveLevels(t, "PUT", `{"name": "handler-register-test", "priority": "INFO"}`); code != http.StatusNotFound {
+		t.Errorf("expected %d, got %d", http.StatusNotFound, code)
+	}
+}
@@ -0,0 +1,70 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+type jsonFormatter struct{}
+
+func (f *jsonFormatter) Format(fields Fields) ([]byte, error) {
+	fields = entryTime(fields)
+	m := make(map[string]json.RawMessage, len(fields))
+	for key, fn := range fields {
+		v := jsonValue(fn())
+		b, err := json.Marshal(v)
+		if err != nil {
+			b, err = json.Marshal(fmt.Sprint(v))
+			if err != nil {
+				return nil, err
+			}
+		}
+		m[key] = b
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// JSONFormatter returns a formatter which evaluates every field and encodes them
+// as a single JSON object terminated by a newline. Values which can not be
+// represented in JSON are encoded using their default string representation.
+// The "time" field holds the time of the entry in RFC 3339 format, taken from
+// the "full_time" field which is otherwise left out.
+func JSONFormatter() Formatter {
+	return &jsonFormatter{}
+}
+
+// JSONSink creates a new sink that writes log messages to w as JSON objects,
+// one per line. Every field supplied by the logger is included in the object.
+func JSONSink(w io.Writer) Sink {
+	return FormatSink(w, JSONFormatter())
+}
@@ -0,0 +1,58 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := JSONSink(&buf)
+	sink.Log(Fields{
+		"priority":     func() interface{} { return INFO },
+		"message":      func() interface{} { return "hello!" },
+		"seq":          func() interface{} { return uint64(1) },
+		"elapsed_time": func() interface{} { return time.Second },
+		"time":         func() interface{} { return "Oct 17 00:22:25" },
+		"full_time":    func() interface{} { return time.Date(2026, 10, 17, 0, 22, 25, 0, time.UTC) },
+		"err":          func() interface{} { return errors.New("boom") },
+		"ch":           func() interface{} { return make(chan int) },
+	})
+	out := buf.String()
+	for _, s := range []string{
+		`"elapsed_time":1000000000`,
+		`"err":"boom"`,
+		`"message":"hello!"`,
+		`"priority":"INFO"`,
+		`"seq":1`,
+		`"time":"2026-10-17T00:22:25Z"`,
+		`"ch":"0x`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %s in output %q", s, out)
+		}
+	}
+	if strings.Contains(out, "full_time") {
+		t.Errorf("expected full_time to be left out of output %q", out)
+	}
+	if out[len(out)-1] != '\n' {
+		t.Errorf("expected output to end with a newline")
+	}
+}
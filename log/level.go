@@ -0,0 +1,56 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import "sync/atomic"
+
+// A Leveler provides a Priority. It is implemented by Priority itself, for a
+// fixed threshold, and by *LevelVar, for a threshold that can change while the
+// program is running.
+type Leveler interface {
+	Level() Priority
+}
+
+// Level returns p, so that a Priority satisfies the Leveler interface.
+func (p Priority) Level() Priority {
+	return p
+}
+
+// LevelVar is a Priority which can be safely read and changed from multiple
+// goroutines. The zero value of a LevelVar is EMERGENCY.
+type LevelVar struct {
+	p int32
+}
+
+// NewLevelVar returns a new LevelVar set to p.
+func NewLevelVar(p Priority) *LevelVar {
+	v := new(LevelVar)
+	v.Set(p)
+	return v
+}
+
+// Level returns the current value of v.
+func (v *LevelVar) Level() Priority {
+	return Priority(atomic.LoadInt32(&v.p))
+}
+
+// Set changes the value of v to p.
+func (v *LevelVar) Set(p Priority) {
+	atomic.StoreInt32(&v.p, int32(p))
+}
+
+func (v *LevelVar) String() string {
+	return "LevelVar(" + v.Level().String() + ")"
+}
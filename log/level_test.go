@@ -0,0 +1,60 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestFilterLevelVar(t *testing.T) {
+	var buf bytes.Buffer
+	level := NewLevelVar(WARNING)
+	logger := New(Filter(level, WriterSink(&buf, "%s\n", []string{"message"})))
+
+	logger.Info("dropped")
+	level.Set(DEBUG)
+	logger.Info("written")
+	level.Set(ERROR)
+	logger.Warning("dropped")
+
+	if buf.String() != "written\n" {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+func TestLevelVarConcurrent(t *testing.T) {
+	level := NewLevelVar(INFO)
+	logger := New(Filter(level, NilSink()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for p := EMERGENCY; p <= DEBUG; p++ {
+				level.Set(p)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Debug("hello")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,413 @@
+package logconfig
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yieldr/go-log/log"
+	"github.com/yieldr/go-log/log/logrotate"
+	"github.com/yieldr/go-log/log/logstream"
+)
+
+var streams = struct {
+	sync.Mutex
+	m map[string]logstream.Stream
+}{m: make(map[string]logstream.Stream)}
+
+// RegisterStream makes s available to logstream sinks under name.
+func RegisterStream(name string, s logstream.Stream) {
+	streams.Lock()
+	streams.m[name] = s
+	streams.Unlock()
+}
+
+func stream(name string) (logstream.Stream, bool) {
+	streams.Lock()
+	defer streams.Unlock()
+	s, ok := streams.m[name]
+	return s, ok
+}
+
+// Build creates the sinks described by c and returns a logger writing to them,
+// along with a Handle used to start their background loops and close them.
+func (c *Config) Build() (log.Logger, *Handle, error) {
+	opts, err := c.options()
+	if err != nil {
+		return nil, nil, err
+	}
+	h := new(Handle)
+	sinks, err := h.buildSinks(c.Sinks, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.register(nil)
+	return log.New(sinks...).WithOptions(opts...), h, nil
+}
+
+func (c *Config) options() ([]log.Option, error) {
+	var opts []log.Option
+	if c.Modules != "" {
+		levels, err := log.ParseModuleLevels(c.Modules)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, log.Levels(levels))
+	}
+	if c.Stacktrace != nil {
+		opts = append(opts, log.AddStacktrace(*c.Stacktrace))
+	}
+	return opts, nil
+}
+
+func formatter(sc SinkConfig) (log.Formatter, error) {
+	switch sc.Formatter {
+	case "", "printf":
+		format, fields := sc.Format, sc.Fields
+		if format == "" {
+			format = log.BasicFormat
+		}
+		if fields == nil {
+			fields = log.BasicFields
+		}
+		return log.PrintfFormatter(format, fields), nil
+	case "json":
+		return log.JSONFormatter(), nil
+	case "logfmt":
+		fields := sc.Fields
+		if fields == nil {
+			fields = log.LogfmtFields
+		}
+		return log.LogfmtFormatter(fields), nil
+	}
+	return nil, fmt.Errorf("unknown formatter %q", sc.Formatter)
+}
+
+// entry is a sink built by a Handle.
+type entry struct {
+	key    string    // identifies the configuration the sink was built from
+	sink   log.Sink  // the sink, before it is wrapped with a filter
+	runner runner    // the background loop of the sink, if any
+	closer io.Closer // closes the sink, if needed
+	quit   chan bool // closed to stop forwarding errors, nil if not running
+}
+
+// key returns a string identifying the sink built from sc, ignoring the filter
+// which wraps it.
+func key(sc SinkConfig) string {
+	sc.Priority = nil
+	sc.Name = ""
+	b, _ := json.Marshal(sc)
+	return string(b)
+}
+
+// buildSinks creates the sinks described by configs and adds them to h. An
+// entry in reuse with the same key is used instead of building a new sink, and
+// removed from reuse. The named filters in kept are reused as described by
+// filter. If an error occurs, the sinks built so far are closed.
+func (h *Handle) buildSinks(configs []SinkConfig, reuse map[string][]*entry, kept map[string]*namedFilter) ([]log.Sink, error) {
+	sinks := make([]log.Sink, 0, len(configs))
+	var built []*entry
+	for i, sc := range configs {
+		k := key(sc)
+		var e *entry
+		if entries := reuse[k]; len(entries) > 0 {
+			e, reuse[k] = entries[0], entries[1:]
+		} else {
+			var err error
+			if e, err = build(sc); err != nil {
+				for _, e := range built {
+					h.close(e)
+				}
+				return nil, fmt.Errorf("logconfig: sink %d: %s", i, err)
+			}
+			e.key = k
+			built = append(built, e)
+		}
+		h.entries = append(h.entries, e)
+		sinks = append(sinks, h.filter(sc, e.sink, kept))
+	}
+	return sinks, nil
+}
+
+// namedFilter is the level of a filter configured with a name.
+type namedFilter struct {
+	level    *log.LevelVar
+	priority log.Priority // the configured priority
+}
+
+// filter wraps s with the filter described by sc, if any. Sinks configured with
+// the same name share a level, which is taken from kept if its configured
+// priority did not change, so that changes made using log.LevelHandler survive
+// a reload. Named filters are only registered once h is in use, by register.
+func (h *Handle) filter(sc SinkConfig, s log.Sink, kept map[string]*namedFilter) log.Sink {
+	if sc.Priority == nil {
+		return s
+	}
+	if sc.Name == "" {
+		return log.Filter(*sc.Priority, s)
+	}
+	f, ok := h.filters[sc.Name]
+	if !ok {
+		if f, ok = kept[sc.Name]; !ok || f.priority != *sc.Priority {
+			f = &namedFilter{log.NewLevelVar(*sc.Priority), *sc.Priority}
+		}
+		if h.filters == nil {
+			h.filters = make(map[string]*namedFilter)
+		}
+		h.filters[sc.Name] = f
+	}
+	return log.Filter(f.level, s)
+}
+
+// register registers the named filters of h using log.RegisterFilter, and
+// unregisters the names in old which h no longer uses.
+func (h *Handle) register(old map[string]*namedFilter) {
+	for name, f := range h.filters {
+		log.RegisterFilter(name, f.level)
+	}
+	for name := range old {
+		if _, ok := h.filters[name]; !ok {
+			log.UnregisterFilter(name)
+		}
+	}
+}
+
+// build creates the sink described by sc.
+func build(sc SinkConfig) (*entry, error) {
+	f, err := formatter(sc)
+	if err != nil {
+		return nil, err
+	}
+	switch sc.Type {
+	case "writer":
+		switch sc.Output {
+		case "", "stdout":
+			return &entry{sink: log.FormatSink(os.Stdout, f)}, nil
+		case "stderr":
+			return &entry{sink: log.FormatSink(os.Stderr, f)}, nil
+		}
+		return nil, fmt.Errorf("unknown output %q", sc.Output)
+	case "syslog":
+		p := log.DEBUG
+		if sc.Priority != nil {
+			p = *sc.Priority
+		}
+		sink, err := syslogSink(p, sc.Tag, f)
+		if err != nil {
+			return nil, err
+		}
+		return &entry{sink: sink, closer: sink}, nil
+	case "logrotate":
+		if sc.Filename == "" {
+			return nil, fmt.Errorf("missing filename")
+		}
+		if sc.Interval <= 0 && sc.Schedule == "" && sc.MaxSize <= 0 {
+			return nil, fmt.Errorf("missing interval, schedule or max_size")
+		}
+		opts, err := rotateOptions(sc)
+		if err != nil {
+			return nil, err
+		}
+		sink, err := logrotate.NewWithFormatter(sc.Filename, time.Duration(sc.Interval), f, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &entry{sink: sink, runner: sink, closer: sink}, nil
+	case "logstream":
+		stream, ok := stream(sc.Stream)
+		if !ok {
+			return nil, fmt.Errorf("unknown stream %q", sc.Stream)
+		}
+		if sc.Interval <= 0 {
+			return nil, fmt.Errorf("missing interval")
+		}
+		sink := logstream.NewWithFormatter(stream, time.Duration(sc.Interval), f)
+		return &entry{sink: sink, runner: sink, closer: sink}, nil
+	case "nil":
+		return &entry{sink: log.NilSink()}, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", sc.Type)
+}
+
+// rotateOptions returns the options of the logrotate sink described by sc.
+func rotateOptions(sc SinkConfig) ([]logrotate.Option, error) {
+	var opts []logrotate.Option
+	if sc.MaxSize > 0 {
+		opts = append(opts, logrotate.MaxSize(sc.MaxSize))
+	}
+	if sc.MaxBackups > 0 {
+		opts = append(opts, logrotate.MaxBackups(sc.MaxBackups))
+	}
+	if sc.MaxAge > 0 {
+		opts = append(opts, logrotate.MaxAge(time.Duration(sc.MaxAge)))
+	}
+	if sc.MaxTotalSize > 0 {
+		opts = append(opts, logrotate.MaxTotalSize(sc.MaxTotalSize))
+	}
+	if sc.Watch > 0 {
+		opts = append(opts, logrotate.Watch(time.Duration(sc.Watch)))
+	}
+	if sc.Schedule != "" {
+		var loc *time.Location // the local time zone
+		if sc.Location != "" {
+			var err error
+			if loc, err = time.LoadLocation(sc.Location); err != nil {
+				return nil, err
+			}
+		}
+		s, err := logrotate.ParseCron(sc.Schedule, loc)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, logrotate.Every(s))
+	}
+	switch sc.Compress {
+	case "":
+	case "gzip":
+		opts = append(opts, logrotate.Compress(logrotate.Gzip))
+	default:
+		return nil, fmt.Errorf("unknown compression %q", sc.Compress)
+	}
+	return opts, nil
+}
+
+// runner is implemented by sinks with a background loop, such as Logrotate and
+// Logstream.
+type runner interface {
+	Run()
+	Stop()
+	Error() <-chan error
+}
+
+// Handle controls the background loops of the sinks built from a Config and
+// closes them.
+type Handle struct {
+	entries []*entry
+	filters map[string]*namedFilter // by name
+	running bool
+	errs    chan error
+	mux     sync.Mutex
+}
+
+// Start runs the background loops of the sinks, such as rotating and flushing
+// files, each in its own goroutine. Errors reported by the loops are available
+// using Error.
+func (h *Handle) Start() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.running = true
+	for _, e := range h.entries {
+		h.run(e)
+	}
+}
+
+// Stop ends the background loops started by Start.
+func (h *Handle) Stop() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.running = false
+	for _, e := range h.entries {
+		h.halt(e)
+	}
+}
+
+// Error returns a channel which receives the errors reported by the background
+// loops. Errors are dropped if the channel is not drained.
+func (h *Handle) Error() <-chan error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return h.errors()
+}
+
+func (h *Handle) errors() chan error {
+	if h.errs == nil {
+		h.errs = make(chan error, 16)
+	}
+	return h.errs
+}
+
+// Close stops the background loops, then flushes and closes every sink in the
+// order they were configured, and unregisters the named filters. It returns the
+// first error encountered.
+func (h *Handle) Close() error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.running = false
+	for name := range h.filters {
+		log.UnregisterFilter(name)
+	}
+	var first error
+	for _, e := range h.entries {
+		if err := h.close(e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// run starts the background loop of e, if it has one and it is not running,
+// and forwards its errors to h.
+func (h *Handle) run(e *entry) {
+	if e.runner == nil || e.quit != nil {
+		return
+	}
+	e.quit = make(chan bool)
+	go e.runner.Run()
+	go func(r runner, quit chan bool, errs chan error) {
+		for {
+			select {
+			case err := <-r.Error():
+				select {
+				case errs <- err:
+				default: // nobody is listening, drop the error.
+				}
+			case <-quit:
+				return
+			}
+		}
+	}(e.runner, e.quit, h.errors())
+}
+
+// halt stops the background loop of e, if it is running.
+func (h *Handle) halt(e *entry) {
+	if e.quit == nil {
+		return
+	}
+	e.runner.Stop()
+	close(e.quit)
+	e.quit = nil
+}
+
+// close stops the background loop of e, then flushes and closes it.
+func (h *Handle) close(e *entry) error {
+	h.halt(e)
+	if e.closer == nil {
+		return nil
+	}
+	var first error
+	if f, ok := e.closer.(log.Flusher); ok {
+		first = f.Flush()
+	}
+	if err := e.closer.Close(); err != nil && first == nil {
+		first = err
+	}
+	return first
+}
@@ -0,0 +1,215 @@
+package logconfig
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yieldr/go-log/log"
+	"gopkg.in/yaml.v2"
+)
+
+// Config describes a logger and the tree of sinks it writes to.
+//
+// An example in YAML:
+//
+//	modules: "*=INFO,bidder=DEBUG"
+//	stacktrace: ERROR
+//	sinks:
+//	  - type: writer
+//	    output: stderr
+//	    formatter: logfmt
+//	    priority: WARNING
+//	  - type: logrotate
+//	    filename: /var/log/app.log
+//	    interval: 24h
+//	    formatter: json
+type Config struct {
+	Sinks      []SinkConfig  `json:"sinks"`
+	Modules    string        `json:"modules"`    // spec of log.ParseModuleLevels
+	Stacktrace *log.Priority `json:"stacktrace"` // priority at or above which stack traces are captured
+}
+
+// SinkConfig describes a single sink.
+type SinkConfig struct {
+	// Type is one of "writer", "syslog", "logrotate", "logstream" or "nil".
+	Type string `json:"type"`
+
+	// Priority, if set, wraps the sink with log.Filter. If Name is also set
+	// the filter is registered under that name using log.RegisterFilter, so
+	// its priority can be changed using log.LevelHandler.
+	Priority *log.Priority `json:"priority"`
+	Name     string        `json:"name"`
+
+	// Formatter is one of "printf" (the default), "json" or "logfmt". Format
+	// and Fields are used by the printf formatter and default to
+	// log.BasicFormat and log.BasicFields. Fields is used by the logfmt
+	// formatter to order fields and defaults to log.LogfmtFields.
+	Formatter string   `json:"formatter"`
+	Format    string   `json:"format"`
+	Fields    []string `json:"fields"`
+
+	// Output is used by the writer sink and is either "stdout" (the default)
+	// or "stderr".
+	Output string `json:"output"`
+
+	// Tag is used by the syslog sink.
+	Tag string `json:"tag"`
+
+	// Filename is used by the logrotate sink.
+	Filename string `json:"filename"`
+
+	// MaxSize is the size in bytes at which the logrotate sink rotates its
+	// file, in addition to rotating it on the interval.
+	MaxSize int64 `json:"max_size"`
+
+	// MaxBackups, MaxAge and MaxTotalSize limit the rotated files kept by the
+	// logrotate sink. Each is disabled if not set.
+	MaxBackups   int      `json:"max_backups"`
+	MaxAge       Duration `json:"max_age"`
+	MaxTotalSize int64    `json:"max_total_size"`
+
+	// Schedule is a crontab(5) specification, parsed by logrotate.ParseCron,
+	// on which the logrotate sink rotates its file instead of the interval.
+	// Location is the name of the time zone of the schedule, such as
+	// "Europe/Amsterdam", and defaults to the local time zone.
+	Schedule string `json:"schedule"`
+	Location string `json:"location"`
+
+	// Watch is the interval at which the logrotate sink checks whether its
+	// file was renamed, deleted or truncated by someone else, and reopens it
+	// if so. It is disabled if not set.
+	Watch Duration `json:"watch"`
+
+	// Compress is the codec used by the logrotate sink to compress rotated
+	// files. It is either empty, for no compression, or "gzip".
+	Compress string `json:"compress"`
+
+	// Interval is the rotation interval of the logrotate sink and the flush
+	// interval of the logstream sink. The logrotate sink needs an interval, a
+	// schedule or a max size.
+	Interval Duration `json:"interval"`
+
+	// Stream is the name of the stream, registered using RegisterStream, used
+	// by the logstream sink.
+	Stream string `json:"stream"`
+}
+
+// Duration is a time.Duration which is encoded as a string such as "1h30m".
+type Duration time.Duration
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("logconfig: invalid duration %s, expected a string such as \"1h\"", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("logconfig: invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ParseJSON parses a JSON document into a Config.
+func ParseJSON(b []byte) (*Config, error) {
+	c := new(Config)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("logconfig: %s", err)
+	}
+	return c, nil
+}
+
+// ParseYAML parses a YAML document into a Config. The document uses the same
+// keys as its JSON counterpart.
+func ParseYAML(b []byte) (*Config, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("logconfig: %s", err)
+	}
+	v, err := jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+	b, err = json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("logconfig: %s", err)
+	}
+	return ParseJSON(b)
+}
+
+// jsonValue converts the maps decoded by yaml, which have interface{} keys, to
+// maps which can be encoded as JSON.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("logconfig: unsupported key %v", key)
+			}
+			value, err := jsonValue(value)
+			if err != nil {
+				return nil, err
+			}
+			m[s] = value
+		}
+		return m, nil
+	case []interface{}:
+		for i := range v {
+			value, err := jsonValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = value
+		}
+	}
+	return v, nil
+}
+
+// Load reads the file at path into a Config. Files ending in .yaml or .yml are
+// parsed as YAML, anything else as JSON.
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseYAML(b)
+	}
+	return ParseJSON(b)
+}
+
+// Open reads the file at path and builds the logger it describes.
+func Open(path string) (log.Logger, *Handle, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.Build()
+}
@@ -0,0 +1,172 @@
+package logconfig
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yieldr/go-log/log"
+	"github.com/yieldr/go-log/log/logstream"
+)
+
+type bufferStream struct {
+	buf    bytes.Buffer
+	closed bool
+	mux    sync.Mutex
+}
+
+func (s *bufferStream) Put(records []logstream.StreamRecord) (logstream.StreamResponse, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, r := range records {
+		s.buf.Write(r)
+	}
+	return nil, nil
+}
+
+func (s *bufferStream) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *bufferStream) String() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.buf.String()
+}
+
+func TestParseYAML(t *testing.T) {
+	c, err := ParseYAML([]byte(`
+modules: "*=INFO"
+stacktrace: err
+sinks:
+  - type: writer
+    output: stderr
+    formatter: logfmt
+    priority: warn
+  - type: logrotate
+    filename: /tmp/app.log
+    interval: 1h
+    max_age: 168h
+    fields: [time, message]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Modules != "*=INFO" || c.Stacktrace == nil || *c.Stacktrace != log.ERROR {
+		t.Errorf("unexpected config %+v", c)
+	}
+	if len(c.Sinks) != 2 {
+		t.Fatalf("expected 2 sinks, got %d", len(c.Sinks))
+	}
+	if s := c.Sinks[0]; s.Type != "writer" || s.Output != "stderr" || s.Formatter != "logfmt" || *s.Priority != log.WARNING {
+		t.Errorf("unexpected sink %+v", s)
+	}
+	if s := c.Sinks[1]; s.Filename != "/tmp/app.log" || time.Duration(s.Interval) != time.Hour || time.Duration(s.MaxAge) != 168*time.Hour || len(s.Fields) != 2 {
+		t.Errorf("unexpected sink %+v", s)
+	}
+}
+
+func TestParseJSONInvalid(t *testing.T) {
+	for _, doc := range []string{
+		`{"sinks": [{"type": "logrotate", "interval": 3600}]}`,
+		`{"sinks": [{"type": "writer", "priority": "LOUD"}]}`,
+		`{"sinks": `,
+	} {
+		if _, err := ParseJSON([]byte(doc)); err == nil {
+			t.Errorf("expected an error parsing %s", doc)
+		}
+	}
+}
+
+func TestBuildInvalid(t *testing.T) {
+	for _, doc := range []string{
+		`{"sinks": [{"type": "unknown"}]}`,
+		`{"sinks": [{"type": "writer", "formatter": "xml"}]}`,
+		`{"sinks": [{"type": "writer", "output": "printer"}]}`,
+		`{"sinks": [{"type": "logrotate", "interval": "1h"}]}`,
+		`{"sinks": [{"type": "logrotate", "filename": "/tmp/app.log"}]}`,
+		`{"sinks": [{"type": "logrotate", "filename": "/tmp/app.log", "interval": "1h", "compress": "zip"}]}`,
+		`{"sinks": [{"type": "logrotate", "filename": "/tmp/app.log", "schedule": "@often"}]}`,
+		`{"sinks": [{"type": "logrotate", "filename": "/tmp/app.log", "schedule": "@daily", "location": "Nowhere/Town"}]}`,
+		`{"sinks": [{"type": "logstream", "stream": "missing", "interval": "1s"}]}`,
+		`{"modules": "a=LOUD"}`,
+	} {
+		c, err := ParseJSON([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.Build(); err == nil {
+			t.Errorf("expected an error building %s", doc)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stream := new(bufferStream)
+	RegisterStream("test", stream)
+
+	path := filepath.Join(dir, "log.json")
+	doc := `{
+		"sinks": [
+			{"type": "logrotate", "filename": "` + filepath.Join(dir, "app.log") + `", "interval": "1h", "format": "%s %s\n", "fields": ["priority", "message"]},
+			{"type": "logstream", "stream": "test", "interval": "1h", "formatter": "json", "priority": "WARNING", "name": "logconfig-test"},
+			{"type": "nil"}
+		]
+	}`
+	if err := ioutil.WriteFile(path, []byte(doc), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	logger, h, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Start()
+	logger.Info("info")
+	logger.Warning("warning")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "INFO info\nWARNING warning\n" {
+		t.Errorf("unexpected file contents %q", b)
+	}
+	if s := stream.String(); strings.Count(s, "\n") != 1 || !strings.Contains(s, `"message":"warning"`) {
+		t.Errorf("unexpected stream contents %q", s)
+	}
+	if !stream.closed {
+		t.Error("expected the stream to be closed")
+	}
+}
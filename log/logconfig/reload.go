@@ -0,0 +1,278 @@
+package logconfig
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/yieldr/go-log/log"
+)
+
+// swapSink passes log entries to a tree of sinks which can be replaced while
+// other goroutines are logging.
+type swapSink struct {
+	sinks []log.Sink
+	mux   sync.RWMutex
+}
+
+func (s *swapSink) Log(fields log.Fields) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	for _, sink := range s.sinks {
+		sink.Log(fields)
+	}
+}
+
+// Flush flushes the current sinks. Closing them is left to Reloader.Close.
+func (s *swapSink) Flush() error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	var first error
+	for _, sink := range s.sinks {
+		if f, ok := sink.(log.Flusher); ok {
+			if err := f.Flush(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// Reloader provides a logger whose sinks are described by a configuration file,
+// and rebuilds them whenever the file is reloaded.
+//
+// On reload, sinks whose configuration did not change are kept and reloaded,
+// e.g. a Logrotate reopens its file. Sinks which are no longer configured are
+// stopped, flushed and closed, and new sinks are built and started. The sinks
+// are swapped while logging is briefly blocked, so no entry is lost or written
+// twice. Named filters keep the priority set using log.LevelHandler unless their
+// configured priority changes, and names no longer configured are unregistered.
+//
+// The module levels are updated on reload, the stack trace priority is only
+// read when the Reloader is created.
+type Reloader struct {
+	path    string
+	poll    time.Duration
+	sink    *swapSink
+	levels  *log.ModuleLevels
+	logger  log.Logger
+	handle  *Handle
+	modTime time.Time
+	size    int64
+	err     chan error
+	stop    chan bool
+	mux     sync.Mutex
+}
+
+// NewReloader builds the logger described by the file at path. If poll is
+// greater than zero, Run checks whether the file changed on that interval.
+func NewReloader(path string, poll time.Duration) (*Reloader, error) {
+	r := &Reloader{
+		path:   path,
+		poll:   poll,
+		sink:   new(swapSink),
+		levels: log.NewModuleLevels(),
+		handle: new(Handle),
+		err:    make(chan error, 16),
+		stop:   make(chan bool),
+	}
+	r.handle.errs = r.err
+	c, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.levels.Parse(c.Modules); err != nil {
+		return nil, err
+	}
+	if r.sink.sinks, err = r.handle.buildSinks(c.Sinks, nil, nil); err != nil {
+		return nil, err
+	}
+	r.handle.register(nil)
+	opts := []log.Option{log.Levels(r.levels)}
+	if c.Stacktrace != nil {
+		opts = append(opts, log.AddStacktrace(*c.Stacktrace))
+	}
+	r.logger = log.New(r.sink).WithOptions(opts...)
+	return r, nil
+}
+
+// load reads the configuration file, remembering its modification time and
+// size.
+func (r *Reloader) load() (*Config, error) {
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Load(r.path)
+	if err != nil {
+		return nil, err
+	}
+	r.modTime, r.size = fi.ModTime(), fi.Size()
+	return c, nil
+}
+
+// changed reports whether the configuration file changed since it was last
+// loaded.
+func (r *Reloader) changed() bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+	return !fi.ModTime().Equal(r.modTime) || fi.Size() != r.size
+}
+
+// Logger returns the logger writing to the configured sinks. It stays valid
+// across reloads.
+func (r *Reloader) Logger() log.Logger {
+	return r.logger
+}
+
+// Reload reads the configuration file and swaps the sinks of the logger. If the
+// file can not be read or a sink can not be built, the current sinks are kept
+// and the error is returned.
+func (r *Reloader) Reload() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	c, err := r.load()
+	if err != nil {
+		return err
+	}
+	if _, err := log.ParseModuleLevels(c.Modules); err != nil {
+		return err
+	}
+
+	old := r.handle
+	reuse := make(map[string][]*entry, len(old.entries))
+	for _, e := range old.entries {
+		reuse[e.key] = append(reuse[e.key], e)
+	}
+	h := &Handle{errs: r.err, running: old.running}
+	sinks, err := h.buildSinks(c.Sinks, reuse, old.filters)
+	if err != nil {
+		return err
+	}
+	if h.running {
+		for _, e := range h.entries {
+			h.run(e)
+		}
+	}
+
+	kept := make(map[*entry]bool, len(h.entries))
+	for _, e := range h.entries {
+		kept[e] = true
+	}
+
+	// Once the write lock is held, no Log call is using the old sinks, so they
+	// are reloaded and closed after unlocking without blocking logging.
+	r.sink.mux.Lock()
+	r.sink.sinks = sinks
+	r.levels.Parse(c.Modules)
+	r.handle = h
+	r.sink.mux.Unlock()
+	h.register(old.filters)
+
+	var first error
+	for _, e := range old.entries {
+		if kept[e] {
+			if reloader, ok := e.sink.(interface {
+				Reload() error
+			}); ok {
+				err = reloader.Reload()
+			}
+		} else {
+			err = old.close(e)
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Run starts the background loops of the sinks and blocks, reloading the
+// configuration when the process receives SIGHUP or, if polling is enabled,
+// when the file changes. Errors encountered during a reload or reported by the
+// sinks are sent to the channel returned by Error. This method will only
+// return once the Stop method is called, stopping the loops of the sinks.
+func (r *Reloader) Run() {
+	r.mux.Lock()
+	r.handle.Start()
+	r.mux.Unlock()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	if r.poll > 0 {
+		ticker := time.NewTicker(r.poll)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-hup:
+			r.report(r.Reload())
+		case <-poll:
+			if r.changed() {
+				r.report(r.Reload())
+			}
+		case <-r.stop:
+			r.mux.Lock()
+			r.handle.Stop()
+			r.mux.Unlock()
+			return
+		}
+	}
+}
+
+func (r *Reloader) report(err error) {
+	if err == nil {
+		return
+	}
+	select {
+	case r.err <- err:
+	default: // nobody is listening, drop the error.
+	}
+}
+
+// Stop ends the execution of Run.
+func (r *Reloader) Stop() {
+	r.stop <- true
+}
+
+// Error returns a channel which receives errors encountered while reloading
+// the configuration or reported by the background loops of the sinks. Errors
+// are dropped if the channel is not drained.
+func (r *Reloader) Error() <-chan error {
+	return r.err
+}
+
+// Close stops the background loops of the sinks, then flushes and closes them.
+func (r *Reloader) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.sink.mux.Lock()
+	defer r.sink.mux.Unlock()
+	return r.handle.Close()
+}
@@ -0,0 +1,297 @@
+package logconfig
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/yieldr/go-log/log"
+	"github.com/yieldr/go-log/log/logstream"
+)
+
+func rotateConfig(files ...string) string {
+	doc := `{"sinks": [`
+	for i, file := range files {
+		if i > 0 {
+			doc += ","
+		}
+		doc += `{"type": "logrotate", "filename": "` + file + `", "interval": "1h", "format": "%s\n", "fields": ["message"]}`
+	}
+	return doc + `]}`
+}
+
+func readFile(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	path := filepath.Join(dir, "log.json")
+	if err := ioutil.WriteFile(path, []byte(rotateConfig(a)), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReloader(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := r.Logger()
+	logger.Info("one")
+	sink := r.handle.entries[0].sink
+
+	// keep a, add b.
+	if err := ioutil.WriteFile(path, []byte(rotateConfig(a, b)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if r.handle.entries[0].sink != sink {
+		t.Error("expected the unchanged sink to be kept")
+	}
+	logger.Info("two")
+
+	// remove a.
+	if err := ioutil.WriteFile(path, []byte(rotateConfig(b)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("three")
+
+	// an invalid configuration keeps the current sinks.
+	if err := ioutil.WriteFile(path, []byte(`{"sinks": [{"type": "unknown"}]}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("expected an error")
+	}
+	logger.Info("four")
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s := readFile(t, a); s != "one\ntwo\n" {
+		t.Errorf("unexpected contents of a %q", s)
+	}
+	if s := readFile(t, b); s != "two\nthree\nfour\n" {
+		t.Errorf("unexpected contents of b %q", s)
+	}
+}
+
+func TestReloaderNamedFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "log.json")
+	load := func(doc string) {
+		if err := ioutil.WriteFile(path, []byte(doc), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	level := func(name string) (log.Priority, bool) {
+		l, ok := log.FilterLevel(name)
+		if !ok {
+			return 0, false
+		}
+		return l.Level(), true
+	}
+
+	load(`{"sinks": [{"type": "nil", "name": "reload-x", "priority": "WARNING"}, {"type": "nil", "name": "reload-y", "priority": "WARNING"}]}`)
+	r, err := NewReloader(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := log.FilterLevel("reload-x")
+	if !ok {
+		t.Fatal("expected reload-x to be registered")
+	}
+	l.Set(log.DEBUG) // as changed using log.LevelHandler
+
+	// a failed reload registers nothing.
+	load(`{"sinks": [{"type": "nil", "name": "reload-z", "priority": "INFO"}, {"type": "unknown"}]}`)
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, ok := level("reload-z"); ok {
+		t.Error("expected reload-z not to be registered")
+	}
+
+	// a kept name keeps its level, a removed name is unregistered.
+	load(`{"sinks": [{"type": "nil", "name": "reload-x", "priority": "WARNING"}]}`)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := level("reload-x"); p != log.DEBUG {
+		t.Errorf("expected reload-x to stay at DEBUG, got %s", p)
+	}
+	if _, ok := level("reload-y"); ok {
+		t.Error("expected reload-y to be unregistered")
+	}
+
+	// a changed priority replaces the level.
+	load(`{"sinks": [{"type": "nil", "name": "reload-x", "priority": "ERROR"}]}`)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := level("reload-x"); p != log.ERROR {
+		t.Errorf("expected reload-x to be ERROR, got %s", p)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := level("reload-x"); ok {
+		t.Error("expected reload-x to be unregistered on close")
+	}
+}
+
+type blockingStream struct {
+	bufferStream
+	putting chan bool
+	release chan bool
+}
+
+func (s *blockingStream) Put(records []logstream.StreamRecord) (logstream.StreamResponse, error) {
+	s.putting <- true
+	<-s.release
+	return s.bufferStream.Put(records)
+}
+
+func TestReloaderCloseUnlocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stream := &blockingStream{putting: make(chan bool, 1), release: make(chan bool)}
+	RegisterStream("blocking", stream)
+
+	a := filepath.Join(dir, "a.log")
+	path := filepath.Join(dir, "log.json")
+	doc := `{"sinks": [{"type": "logstream", "stream": "blocking", "interval": "1h", "format": "%s\n", "fields": ["message"]}]}`
+	if err := ioutil.WriteFile(path, []byte(doc), 0666); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := r.Logger()
+	logger.Info("one")
+
+	// the removed logstream sink blocks while it is flushed.
+	if err := ioutil.WriteFile(path, []byte(rotateConfig(a)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := make(chan error)
+	go func() { reloaded <- r.Reload() }()
+
+	// the sinks are swapped before the removed sink is flushed.
+	<-stream.putting
+	logged := make(chan bool)
+	go func() {
+		logger.Info("two")
+		logged <- true
+	}()
+
+	select {
+	case <-logged:
+	case <-time.After(time.Second):
+		t.Error("expected logging not to be blocked while removed sinks are closed")
+	}
+	close(stream.release)
+	if err := <-reloaded; err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s := stream.String(); s != "one\n" {
+		t.Errorf("unexpected contents of the stream %q", s)
+	}
+	if s := readFile(t, a); s != "two\n" {
+		t.Errorf("unexpected contents of a %q", s)
+	}
+}
+
+func TestReloaderRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b, c := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log"), filepath.Join(dir, "c.log")
+	path := filepath.Join(dir, "log.json")
+	if err := ioutil.WriteFile(path, []byte(rotateConfig(a)), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReloader(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go r.Run()
+	defer r.Close()
+
+	waitFor := func(file string) {
+		for i := 0; i < 100; i++ {
+			if _, err := os.Stat(file); err == nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %s to be created", file)
+	}
+
+	// reloaded because the file changed.
+	if err := ioutil.WriteFile(path, []byte(rotateConfig(a, b)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(b)
+
+	// reloaded on SIGHUP, keeping the modification time and size the same so
+	// the change is not noticed otherwise.
+	fi, _ := os.Stat(path)
+	if err := ioutil.WriteFile(path, []byte(rotateConfig(a, c)), 0666); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, fi.ModTime(), fi.ModTime())
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	waitFor(c)
+
+	r.Stop()
+}
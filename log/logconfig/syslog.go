@@ -0,0 +1,33 @@
+//go:build !windows
+// +build !windows
+
+package logconfig
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"io"
+
+	"github.com/yieldr/go-log/log"
+)
+
+type syslogCloser interface {
+	log.Sink
+	io.Closer
+}
+
+func syslogSink(p log.Priority, tag string, f log.Formatter) (syslogCloser, error) {
+	return log.SyslogFormatSink(p, tag, f)
+}
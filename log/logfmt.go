@@ -0,0 +1,117 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type logfmtFormatter struct {
+	order []string
+}
+
+func (f *logfmtFormatter) Format(fields Fields) ([]byte, error) {
+	fields = entryTime(fields)
+	var buf bytes.Buffer
+	seen := make(map[string]bool, len(f.order))
+	for _, key := range f.order {
+		if fn, ok := fields[key]; ok && !seen[key] {
+			writeLogfmt(&buf, key, fn())
+		}
+		seen[key] = true
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeLogfmt(&buf, key, fields[key]())
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func writeLogfmt(buf *bytes.Buffer, key string, value interface{}) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(logfmtKey(key))
+	buf.WriteByte('=')
+	s := logfmtValue(value)
+	if logfmtNeedsQuote(s) {
+		s = strconv.Quote(s)
+	}
+	buf.WriteString(s)
+}
+
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+func logfmtValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+func logfmtNeedsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f || r == utf8.RuneError {
+			return true
+		}
+	}
+	return false
+}
+
+// LogfmtFormatter returns a formatter which renders every field as a logfmt
+// line, e.g. `time=2006-01-02T15:04:05Z priority=INFO message="hello world"`.
+// The fields named in order are written first, followed by any remaining
+// fields in sorted order. Values containing spaces, quotes, equal signs or
+// control characters are quoted and escaped. The "time" field holds the time
+// of the entry in RFC 3339 format, taken from the "full_time" field which is
+// otherwise left out.
+func LogfmtFormatter(order []string) Formatter {
+	return &logfmtFormatter{order}
+}
@@ -0,0 +1,57 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogfmtFormatter(t *testing.T) {
+	f := LogfmtFormatter(LogfmtFields)
+	b, err := f.Format(Fields{
+		"time":     func() interface{} { return "Jan  2 15:04:05" },
+		"priority": func() interface{} { return INFO },
+		"message":  func() interface{} { return "say \"hi\"\nbye" },
+		"seq":      func() interface{} { return uint64(3) },
+		"empty":    func() interface{} { return "" },
+		"a=b":      func() interface{} { return "x=y" },
+		"nothing":  func() interface{} { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `time="Jan  2 15:04:05" priority=INFO message="say \"hi\"\nbye" a_b="x=y" empty="" nothing=null seq=3` + "\n"
+	if string(b) != expected {
+		t.Errorf("expected %q, got %q", expected, b)
+	}
+}
+
+func TestLogfmtFormatterFullTime(t *testing.T) {
+	f := LogfmtFormatter(LogfmtFields)
+	b, err := f.Format(Fields{
+		"time":      func() interface{} { return "Oct 17 01:08:52" },
+		"full_time": func() interface{} { return time.Date(2026, 10, 17, 1, 8, 52, 0, time.UTC) },
+		"priority":  func() interface{} { return INFO },
+		"message":   func() interface{} { return "hello" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "time=2026-10-17T01:08:52Z priority=INFO message=hello\n"
+	if string(b) != expected {
+		t.Errorf("expected %q, got %q", expected, b)
+	}
+}
@@ -16,14 +16,19 @@ package log
 // limitations under the License.
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"runtime"
+	"sync/atomic"
 	"time"
 )
 
 // The Logger interface defines the methods that can be called to perform
 // priority based logging.
 type Logger interface {
+	Log(Priority, ...interface{})
+	Logf(Priority, string, ...interface{})
 	Emergency(...interface{})
 	Emergencyf(string, ...interface{})
 	Alert(...interface{})
@@ -40,6 +45,43 @@ type Logger interface {
 	Infof(string, ...interface{})
 	Debug(...interface{})
 	Debugf(string, ...interface{})
+
+	// With returns a child logger which adds the supplied key/value pairs to
+	// the fields of every log entry.
+	With(...interface{}) Logger
+
+	// WithOptions returns a child logger with the supplied options applied.
+	WithOptions(...Option) Logger
+
+	// Named returns a child logger for the named module.
+	Named(string) Logger
+
+	// The Context variants add the fields extracted from the context to the
+	// log entry. See RegisterContextExtractor.
+	LogContext(context.Context, Priority, ...interface{})
+	EmergencyContext(context.Context, ...interface{})
+	AlertContext(context.Context, ...interface{})
+	CriticalContext(context.Context, ...interface{})
+	ErrorContext(context.Context, ...interface{})
+	WarningContext(context.Context, ...interface{})
+	NoticeContext(context.Context, ...interface{})
+	InfoContext(context.Context, ...interface{})
+	DebugContext(context.Context, ...interface{})
+
+	// Standard library log functions
+	Fatalln(...interface{})
+	Fatalf(string, ...interface{})
+	Panicln(...interface{})
+	Panicf(string, ...interface{})
+	Println(...interface{})
+	Printf(string, ...interface{})
+
+	// Flush flushes every sink which buffers log entries.
+	Flush() error
+
+	// Close flushes and closes every sink which supports it. Child loggers
+	// share the sinks of their parent, so closing any of them closes all.
+	Close() error
 }
 
 var (
@@ -50,236 +92,472 @@ var (
 	BasicFields  = []string{"time", "priority", "message"}
 	RichFields   = []string{"time", "priority", "seq", "prefix", "message"}
 	SyslogFields = []string{"priority", "message"}
+	LogfmtFields = []string{"time", "priority", "message"}
 )
 
 // logger represents an active logging object that forwards log messages to its
 // underlying sinks.
 type logger struct {
-	sinks   []Sink    // the sinks this logger will log to
-	prefix  string    // static field available to all log sinks under this logger
-	created time.Time // time when this logger was created
-	seq     uint64    // sequential number of log message, starting at 1
+	sinks   []Sink                 // the sinks this logger will log to
+	prefix  string                 // static field available to all log sinks under this logger
+	created time.Time              // time when this logger was created
+	seq     *uint64                // sequential number of log message, starting at 1
+	context map[string]interface{} // key/value pairs added using With
+	skip    int                    // additional stack frames to skip when looking up the caller
+	skipPkg string                 // package whose functions are skipped when looking up the caller
+	stack   bool                   // whether to capture stack traces
+	stackP  Priority               // priority at or above which stack traces are captured
+	module  string                 // name of the module, set using Named
+	levels  *ModuleLevels          // thresholds of modules, consulted before sinks
+	ctx     context.Context        // context bound using FromContext
+}
+
+// An Option configures a logger.
+type Option func(*logger)
+
+// AddCallerSkip increases the number of stack frames skipped when looking up
+// the caller of a logging function. It is intended for libraries wrapping a
+// Logger, so that the caller fields point to their callers instead.
+func AddCallerSkip(n int) Option {
+	return func(logger *logger) {
+		logger.skip += n
+	}
+}
+
+// skipPackage skips the functions of the package with import path pkg when
+// looking up the caller, however many stack frames they take up.
+func skipPackage(pkg string) Option {
+	return func(logger *logger) {
+		logger.skipPkg = pkg
+	}
+}
+
+// AddStacktrace captures the stack trace of the caller for every log entry with
+// a priority at or above p, i.e. p or more severe. The stack trace is available
+// to sinks as the "stack" field.
+func AddStacktrace(p Priority) Option {
+	return func(logger *logger) {
+		logger.stack = true
+		logger.stackP = p
+	}
+}
+
+// Levels filters log entries using the threshold of the logger's module in m,
+// before they are passed to any sink. See Named.
+func Levels(m *ModuleLevels) Option {
+	return func(logger *logger) {
+		logger.levels = m
+	}
 }
 
 // New creates a new logger with the supplied options.
 func New(sinks ...Sink) Logger {
 	return &logger{
 		created: time.Now(),
-		seq:     0,
+		seq:     new(uint64),
 		sinks:   sinks,
 	}
 }
 
-func (logger *logger) Log(p Priority, v ...interface{}) {
-	fields := Fields{
-		"priority":     func() interface{} { return p },
-		"message":      func() interface{} { return fmt.Sprint(v...) },
-		"prefix":       logger.prefixFn,  // static field available to all sinks
-		"time":         logger.timeFn,    // formatted time of log entry
-		"start_time":   logger.createdFn, // start time of the logger
-		"elapsed_time": logger.elapsedFn, // relative time of log entry since started
-		"seq":          logger.seqFn,     // auto-incrementing sequence number
-		"pid":          logger.pidFn,     // process id
+// With returns a child logger which shares the sinks and sequence of its parent
+// and adds the key/value pairs in kv to every log entry. Keys are converted to
+// strings and a key without a value is given a nil value. Keys can not override
+// the fields set by the logger such as "message" or "time".
+func (logger *logger) With(kv ...interface{}) Logger {
+	context := make(map[string]interface{}, len(logger.context)+len(kv)/2)
+	for k, v := range logger.context {
+		context[k] = v
+	}
+	for i := 0; i < len(kv); i += 2 {
+		var v interface{}
+		if i+1 < len(kv) {
+			v = kv[i+1]
+		}
+		context[fmt.Sprint(kv[i])] = v
+	}
+	child := *logger
+	child.context = context
+	return &child
+}
+
+// WithOptions returns a child logger which shares the sinks and sequence of its
+// parent with opts applied.
+func (logger *logger) WithOptions(opts ...Option) Logger {
+	child := *logger
+	for _, opt := range opts {
+		opt(&child)
+	}
+	return &child
+}
+
+// Named returns a child logger which shares the sinks and sequence of its
+// parent and adds the "module" field to every log entry. Naming a logger which
+// already has a name creates a sub-module, e.g. "bidder" then "cache" results
+// in "bidder.cache". If the logger was configured with Levels, entries below
+// the threshold of the module are discarded.
+func (logger *logger) Named(name string) Logger {
+	child := *logger
+	if logger.module != "" {
+		child.module = logger.module + "." + name
+	} else {
+		child.module = name
+	}
+	return &child
+}
+
+// output builds the fields of a log entry and passes them to every sink. The
+// depth is the number of stack frames between the caller of the logging
+// function and output.
+func (logger *logger) output(depth int, p Priority, message fieldFn) {
+	logger.log(logger.ctx, depth+1, p, message, logger.stack && p <= logger.stackP)
+}
+
+// outputContext is like output, but extracts fields from ctx instead of the
+// context bound to the logger.
+func (logger *logger) outputContext(ctx context.Context, depth int, p Priority, message fieldFn) {
+	logger.log(ctx, depth+1, p, message, logger.stack && p <= logger.stackP)
+}
+
+// log is like outputContext, but captures a stack trace if stack is true
+// regardless of the options of the logger.
+func (logger *logger) log(ctx context.Context, depth int, p Priority, message fieldFn, stack bool) {
+	if logger.levels != nil && p > logger.levels.Level(logger.module) {
+		return
+	}
+	pcs := make([]uintptr, 1)
+	if stack {
+		pcs = make([]uintptr, 64)
+	} else if logger.skipPkg != "" {
+		pcs = make([]uintptr, 16)
+	}
+	n := runtime.Callers(depth+logger.skip+2, pcs)
+	caller := &caller{pcs: pcs[:n], skipPkg: logger.skipPkg}
+	now := time.Now()
+
+	fields := make(Fields, len(logger.context)+12)
+	for key, value := range contextFields(ctx) {
+		fields[key] = valueFn(value)
+	}
+	for key, value := range logger.context {
+		fields[key] = valueFn(value)
+	}
+	fields["priority"] = func() interface{} { return p }
+	fields["message"] = message
+	fields["prefix"] = logger.prefixFn        // static field available to all sinks
+	fields["time"] = timeFn(now)              // formatted time of log entry
+	fields["full_time"] = valueFn(now)        // time of log entry
+	fields["start_time"] = logger.createdFn   // start time of the logger
+	fields["elapsed_time"] = logger.elapsedFn // relative time of log entry since started
+	fields["seq"] = logger.seqFn              // auto-incrementing sequence number
+	fields["pid"] = logger.pidFn              // process id
+	fields["file"] = caller.fileFn            // full path of the file of the caller
+	fields["line"] = caller.lineFn            // line number of the caller
+	fields["func"] = caller.funcFn            // function name of the caller
+	fields["caller"] = caller.callerFn        // short file name and line number of the caller
+	if logger.module != "" {
+		fields["module"] = valueFn(logger.module) // name of the module of the logger
+	}
+	if stack && n > 0 {
+		fields["stack"] = stackFn(pcs[:n]) // stack trace of the caller
 	}
 	for _, sink := range logger.sinks {
 		sink.Log(fields)
 	}
 }
 
-func (logger *logger) Logf(priority Priority, format string, v ...interface{}) {
-	logger.Log(priority, fmt.Sprintf(format, v...))
+func (logger *logger) Log(p Priority, v ...interface{}) {
+	logger.output(1, p, sprint(v))
+}
+
+func (logger *logger) Logf(p Priority, format string, v ...interface{}) {
+	logger.output(1, p, sprintf(format, v))
 }
 
 func (logger *logger) Emergency(v ...interface{}) {
-	logger.Log(EMERGENCY, v...)
+	logger.output(1, EMERGENCY, sprint(v))
 }
 
 func (logger *logger) Emergencyf(format string, v ...interface{}) {
-	logger.Log(EMERGENCY, fmt.Sprintf(format, v...))
+	logger.output(1, EMERGENCY, sprintf(format, v))
 }
 
 func (logger *logger) Alert(v ...interface{}) {
-	logger.Log(ALERT, v...)
+	logger.output(1, ALERT, sprint(v))
 }
 
 func (logger *logger) Alertf(format string, v ...interface{}) {
-	logger.Log(ALERT, fmt.Sprintf(format, v...))
+	logger.output(1, ALERT, sprintf(format, v))
 }
 
 func (logger *logger) Critical(v ...interface{}) {
-	logger.Log(CRITICAL, v...)
+	logger.output(1, CRITICAL, sprint(v))
 }
 
 func (logger *logger) Criticalf(format string, v ...interface{}) {
-	logger.Log(CRITICAL, fmt.Sprintf(format, v...))
+	logger.output(1, CRITICAL, sprintf(format, v))
 }
 
 func (logger *logger) Error(v ...interface{}) {
-	logger.Log(ERROR, v...)
+	logger.output(1, ERROR, sprint(v))
 }
 
 func (logger *logger) Errorf(format string, v ...interface{}) {
-	logger.Log(ERROR, fmt.Sprintf(format, v...))
+	logger.output(1, ERROR, sprintf(format, v))
 }
 
 func (logger *logger) Warning(v ...interface{}) {
-	logger.Log(WARNING, v...)
+	logger.output(1, WARNING, sprint(v))
 }
 
 func (logger *logger) Warningf(format string, v ...interface{}) {
-	logger.Log(WARNING, fmt.Sprintf(format, v...))
+	logger.output(1, WARNING, sprintf(format, v))
 }
 
 func (logger *logger) Notice(v ...interface{}) {
-	logger.Log(NOTICE, v...)
+	logger.output(1, NOTICE, sprint(v))
 }
 
 func (logger *logger) Noticef(format string, v ...interface{}) {
-	logger.Log(NOTICE, fmt.Sprintf(format, v...))
+	logger.output(1, NOTICE, sprintf(format, v))
 }
 
 func (logger *logger) Info(v ...interface{}) {
-	logger.Log(INFO, v...)
+	logger.output(1, INFO, sprint(v))
 }
 
 func (logger *logger) Infof(format string, v ...interface{}) {
-	logger.Log(INFO, fmt.Sprintf(format, v...))
+	logger.output(1, INFO, sprintf(format, v))
 }
 
 func (logger *logger) Debug(v ...interface{}) {
-	logger.Log(DEBUG, v...)
+	logger.output(1, DEBUG, sprint(v))
 }
 
 func (logger *logger) Debugf(format string, v ...interface{}) {
-	logger.Log(DEBUG, fmt.Sprintf(format, v...))
+	logger.output(1, DEBUG, sprintf(format, v))
+}
+
+// defaultLogger holds the logger used by the package level functions.
+type defaultLogger struct {
+	logger Logger // the logger returned by Default
+	caller Logger // logger skipping the package level function when looking up the caller
+}
+
+// The default logger can be used directly by just importing the package and
+// using it the same way you would use the standard library's log package.
+var defaultValue atomic.Value
+
+func init() {
+	SetDefault(New(WriterSink(os.Stdout, BasicFormat, BasicFields)))
+}
+
+// Default returns the logger used by the package level functions. Unless it is
+// changed using SetDefault, it writes BasicFormat to os.Stdout.
+func Default() Logger {
+	return defaultValue.Load().(*defaultLogger).logger
+}
+
+// SetDefault makes l the logger used by the package level functions, such as
+// Info and Errorf. It is safe to call SetDefault while other goroutines are
+// logging.
+func SetDefault(l Logger) {
+	defaultValue.Store(&defaultLogger{
+		logger: l,
+		caller: l.WithOptions(AddCallerSkip(1)),
+	})
 }
 
-// This logger can be user directly by just importing the package and using it
-// the same way you would use the standard library's log package.
-var stdout = New(WriterSink(os.Stdout, BasicFormat, BasicFields)).(*logger)
+// std returns the default logger for use by the package level functions.
+func std() Logger {
+	return defaultValue.Load().(*defaultLogger).caller
+}
+
+// With returns a child of the default logger which adds the key/value pairs in
+// kv to every log entry.
+func With(kv ...interface{}) Logger {
+	return Default().With(kv...)
+}
+
+// Named returns a child of the default logger for the named module.
+func Named(name string) Logger {
+	return Default().Named(name)
+}
+
+// Flush flushes the sinks of the default logger.
+func Flush() error {
+	return Default().Flush()
+}
+
+// Close flushes and closes the sinks of the default logger.
+func Close() error {
+	return Default().Close()
+}
 
 func Emergency(v ...interface{}) {
-	stdout.Log(EMERGENCY, v...)
+	std().Emergency(v...)
 }
 
 func Emergencyf(format string, v ...interface{}) {
-	stdout.Log(EMERGENCY, fmt.Sprintf(format, v...))
+	std().Emergencyf(format, v...)
 }
 
 func Alert(v ...interface{}) {
-	stdout.Log(ALERT, v...)
+	std().Alert(v...)
 }
 
 func Alertf(format string, v ...interface{}) {
-	stdout.Log(ALERT, fmt.Sprintf(format, v...))
+	std().Alertf(format, v...)
 }
 
 func Critical(v ...interface{}) {
-	stdout.Log(CRITICAL, v...)
+	std().Critical(v...)
 }
 
 func Criticalf(format string, v ...interface{}) {
-	stdout.Log(CRITICAL, fmt.Sprintf(format, v...))
+	std().Criticalf(format, v...)
 }
 
 func Error(v ...interface{}) {
-	stdout.Log(ERROR, v...)
+	std().Error(v...)
 }
 
 func Errorf(format string, v ...interface{}) {
-	stdout.Log(ERROR, fmt.Sprintf(format, v...))
+	std().Errorf(format, v...)
 }
 
 func Warning(v ...interface{}) {
-	stdout.Log(WARNING, v...)
+	std().Warning(v...)
 }
 
 func Warningf(format string, v ...interface{}) {
-	stdout.Log(WARNING, fmt.Sprintf(format, v...))
+	std().Warningf(format, v...)
 }
 
 func Notice(v ...interface{}) {
-	stdout.Log(NOTICE, v...)
+	std().Notice(v...)
 }
 
 func Noticef(format string, v ...interface{}) {
-	stdout.Log(NOTICE, fmt.Sprintf(format, v...))
+	std().Noticef(format, v...)
 }
 
 func Info(v ...interface{}) {
-	stdout.Log(INFO, v...)
+	std().Info(v...)
 }
 
 func Infof(format string, v ...interface{}) {
-	stdout.Log(INFO, fmt.Sprintf(format, v...))
+	std().Infof(format, v...)
 }
 
 func Debug(v ...interface{}) {
-	stdout.Log(DEBUG, v...)
+	std().Debug(v...)
 }
 
 func Debugf(format string, v ...interface{}) {
-	stdout.Log(DEBUG, fmt.Sprintf(format, v...))
+	std().Debugf(format, v...)
+}
+
+// Flush flushes every sink of the logger which implements Flusher, and returns
+// the first error encountered.
+func (logger *logger) Flush() error {
+	var first error
+	for _, sink := range logger.sinks {
+		if err := flushSink(sink); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Close flushes and closes every sink of the logger which implements Flusher or
+// io.Closer, and returns the first error encountered.
+func (logger *logger) Close() error {
+	var first error
+	for _, sink := range logger.sinks {
+		if err := closeSink(sink); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+var flushTimeout = 5 * time.Second
+
+// FlushTimeout sets the maximum time the Fatal functions wait for the sinks to
+// be flushed before exiting.
+func FlushTimeout(d time.Duration) {
+	flushTimeout = d
+}
+
+// exit is called by the Fatal functions, it is replaced during tests.
+var exit = os.Exit
+
+// flushAndExit flushes the sinks, waiting at most flushTimeout, then exits.
+func (logger *logger) flushAndExit() {
+	done := make(chan bool, 1)
+	go func() {
+		logger.Flush()
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(flushTimeout):
+	}
+	exit(1)
 }
 
 // Standard library log functions
 
 func (logger *logger) Fatalln(v ...interface{}) {
-	logger.Log(CRITICAL, v...)
-	os.Exit(1)
+	logger.output(1, CRITICAL, sprint(v))
+	logger.flushAndExit()
 }
 
 func (logger *logger) Fatalf(format string, v ...interface{}) {
-	logger.Logf(CRITICAL, format, v...)
-	os.Exit(1)
+	logger.output(1, CRITICAL, sprintf(format, v))
+	logger.flushAndExit()
 }
 
 func (logger *logger) Panicln(v ...interface{}) {
 	s := fmt.Sprint(v...)
-	logger.Log(ERROR, s)
+	logger.log(logger.ctx, 1, ERROR, valueFn(s), true)
 	panic(s)
 }
 
 func (logger *logger) Panicf(format string, v ...interface{}) {
 	s := fmt.Sprintf(format, v...)
-	logger.Log(ERROR, s)
+	logger.log(logger.ctx, 1, ERROR, valueFn(s), true)
 	panic(s)
 }
 
 func (logger *logger) Println(v ...interface{}) {
-	logger.Log(INFO, v...)
+	logger.output(1, INFO, sprint(v))
 }
 
 func (logger *logger) Printf(format string, v ...interface{}) {
-	logger.Logf(INFO, format, v...)
+	logger.output(1, INFO, sprintf(format, v))
 }
 
 func Fatalln(v ...interface{}) {
-	stdout.Log(CRITICAL, v...)
-	os.Exit(1)
+	std().Fatalln(v...)
 }
 
 func Fatalf(format string, v ...interface{}) {
-	stdout.Logf(CRITICAL, format, v...)
-	os.Exit(1)
+	std().Fatalf(format, v...)
 }
 
 func Panicln(v ...interface{}) {
-	s := fmt.Sprint(v...)
-	stdout.Log(ERROR, s)
-	panic(s)
+	std().Panicln(v...)
 }
 
 func Panicf(format string, v ...interface{}) {
-	s := fmt.Sprintf(format, v...)
-	stdout.Log(ERROR, s)
-	panic(s)
+	std().Panicf(format, v...)
 }
 
 func Println(v ...interface{}) {
-	stdout.Log(INFO, v...)
+	std().Println(v...)
 }
 
 func Printf(format string, v ...interface{}) {
-	stdout.Logf(INFO, format, v...)
+	std().Printf(format, v...)
 }
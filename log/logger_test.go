@@ -0,0 +1,221 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerWith(t *testing.T) {
+	var buf bytes.Buffer
+	parent := New(WriterSink(&buf, "%d %s %v %v\n", []string{"seq", "message", "request_id", "tenant"}))
+	child := parent.With("request_id", "abc", "tenant", 42)
+	grandchild := child.With("tenant", 43, "message", "ignored")
+
+	parent.Info("parent")
+	child.Info("child")
+	grandchild.Info("grandchild")
+
+	expected := "1 parent ??? ???\n" +
+		"2 child abc 42\n" +
+		"3 grandchild abc 43\n"
+
+	if buf.String() != expected {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+func line() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+type wrapper struct {
+	Logger
+}
+
+func (w wrapper) info(v ...interface{}) {
+	w.Logger.Info(v...)
+}
+
+func TestLoggerCaller(t *testing.T) {
+	var buf bytes.Buffer
+	sink := WriterSink(&buf, "%s %s\n", []string{"caller", "func"})
+	l := New(sink).(*logger)
+
+	std := Default()
+	SetDefault(l)
+	defer SetDefault(std)
+
+	expected := []string{}
+	add := func(line int) {
+		expected = append(expected, fmt.Sprintf("logger_test.go:%d github.com/yieldr/go-log/log.TestLoggerCaller", line))
+	}
+
+	l.Info("method")
+	add(line() - 1)
+	l.Warningf("%s", "formatted")
+	add(line() - 1)
+	l.Log(INFO, "log")
+	add(line() - 1)
+	l.Printf("%s", "printf")
+	add(line() - 1)
+	Info("package")
+	add(line() - 1)
+	Errorf("%s", "package formatted")
+	add(line() - 1)
+	Println("package println")
+	add(line() - 1)
+	wrapper{l.WithOptions(AddCallerSkip(1))}.info("wrapped")
+	add(line() - 1)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d", len(expected), len(lines))
+	}
+	for i := range lines {
+		if lines[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], lines[i])
+		}
+	}
+}
+
+func TestLoggerStacktrace(t *testing.T) {
+	var stacks []interface{}
+	sink := sinkFunc(func(fields Fields) {
+		if fn, ok := fields["stack"]; ok {
+			stacks = append(stacks, fn())
+		} else {
+			stacks = append(stacks, nil)
+		}
+	})
+	l := New(sink).WithOptions(AddStacktrace(ERROR)).(*logger)
+	l.Warning("no stack")
+	l.Error("stack")
+	l.Critical("stack")
+	func() {
+		defer func() { recover() }()
+		New(sink).(*logger).Panicln("stack")
+	}()
+
+	if len(stacks) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(stacks))
+	}
+	if stacks[0] != nil {
+		t.Errorf("expected no stack for WARNING, got %q", stacks[0])
+	}
+	for _, stack := range stacks[1:] {
+		s, _ := stack.(string)
+		if !strings.HasPrefix(s, "github.com/yieldr/go-log/log.TestLoggerStacktrace") {
+			t.Errorf("expected stack to start at the caller, got %q", s)
+		}
+	}
+}
+
+type sinkFunc func(Fields)
+
+func (fn sinkFunc) Log(fields Fields) { fn(fields) }
+
+func TestSetDefault(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(WriterSink(&buf, SyslogFormat, SyslogFields))
+
+	std := Default()
+	SetDefault(l)
+	defer SetDefault(std)
+
+	if Default() != l {
+		t.Error("expected Default to return the logger passed to SetDefault")
+	}
+	Info("package")
+	Named("module").Warning("named")
+	With("key", "value").Error("with")
+
+	expected := "[INFO] package\n[WARNING] named\n[ERROR] with\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+type bufferedSink struct {
+	flushed, closed int
+	block           chan bool
+}
+
+func (s *bufferedSink) Log(Fields) {}
+
+func (s *bufferedSink) Flush() error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.flushed++
+	return nil
+}
+
+func (s *bufferedSink) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestLoggerClose(t *testing.T) {
+	s := new(bufferedSink)
+	l := New(s, Filter(ERROR, s), NilSink())
+
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if s.flushed != 2 || s.closed != 0 {
+		t.Errorf("expected 2 flushes and no close, got %d and %d", s.flushed, s.closed)
+	}
+	if err := l.Named("child").Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s.flushed != 4 || s.closed != 2 {
+		t.Errorf("expected 4 flushes and 2 closes, got %d and %d", s.flushed, s.closed)
+	}
+}
+
+func TestLoggerFatal(t *testing.T) {
+	defer func(d time.Duration, fn func(int)) {
+		flushTimeout, exit = d, fn
+	}(flushTimeout, exit)
+
+	var code int
+	exit = func(c int) { code = c }
+
+	s := new(bufferedSink)
+	New(s).Fatalln("fatal")
+	if code != 1 || s.flushed != 1 {
+		t.Errorf("expected exit code 1 after flushing, got %d and %d flushes", code, s.flushed)
+	}
+
+	block := make(chan bool)
+	defer close(block)
+	FlushTimeout(10 * time.Millisecond)
+	code = 0
+	start := time.Now()
+	New(&bufferedSink{block: block}).Fatalf("fatal %d", 2)
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected Fatal to give up flushing after the timeout, took %s", d)
+	}
+}
@@ -0,0 +1,96 @@
+package logrotate
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// Codec compresses rotated files.
+type Codec interface {
+	// Ext returns the extension appended to the name of compressed files, such
+	// as ".gz".
+	Ext() string
+
+	// NewWriter returns a writer which compresses the data written to it into
+	// w. Closing it flushes any pending data but does not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Ext() string { return ".gz" }
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+// Gzip compresses rotated files using gzip.
+var Gzip Codec = gzipCodec{}
+
+// Compress compresses rotated files using c. Compression happens in the
+// background so logging is not blocked, and errors are reported on the channel
+// returned by Error. They are dropped if the channel is not drained. Retention
+// options are applied once a file is compressed.
+func Compress(c Codec) Option {
+	return func(l *Logrotate) {
+		l.codec = c
+	}
+}
+
+// compressFile compresses name into a temporary file which replaces the
+// compressed file only once it is complete, then removes name.
+func compressFile(c Codec, name string) (err error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + c.Ext() + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	w, err := c.NewWriter(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, src); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	if err = dst.Sync(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, name+c.Ext()); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
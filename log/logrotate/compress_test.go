@@ -0,0 +1,169 @@
+package logrotate
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/yieldr/go-log/log"
+)
+
+func TestCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := New(dir+"/test.log", 0, "%s\n", []string{"message"}, Compress(Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.New(sink)
+	logger.Info("one")
+	if err := sink.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("two")
+	if err := sink.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(dir + "/test.log.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 rotated files, got %v", files)
+	}
+	var content string
+	for _, name := range files {
+		if filepath.Ext(name) != ".gz" {
+			t.Fatalf("expected %s to be compressed", name)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		content += string(b)
+	}
+	if content != "one\ntwo\n" && content != "two\none\n" {
+		t.Errorf("unexpected content of compressed files %q", content)
+	}
+}
+
+type failingCodec struct{}
+
+func (failingCodec) Ext() string { return ".fail" }
+
+func (failingCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return failingWriter{}, nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("failed") }
+
+func (failingWriter) Close() error { return nil }
+
+func TestCompressError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := New(dir+"/test.log", 0, "%s\n", []string{"message"}, Compress(failingCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	log.New(sink).Info("one")
+	if err := sink.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-sink.Error():
+		if err.Error() != "failed" {
+			t.Errorf("unexpected error %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the compression error to be reported")
+	}
+
+	// the rotated file is kept as is, without a partially compressed copy.
+	files, err := filepath.Glob(dir + "/test.log.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Ext(files[0]) == ".fail" || filepath.Ext(files[0]) == ".tmp" {
+		t.Errorf("expected only the uncompressed file, got %v", files)
+	}
+}
+
+func TestCompressErrorUnread(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	before := runtime.NumGoroutine()
+	sink, err := New(dir+"/test.log", 0, "%s\n", []string{"message"}, Compress(failingCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// more failures than the error channel holds, none of which are read.
+	for i := 0; i < 2*cap(sink.err); i++ {
+		if _, err := sink.Write([]byte("entry\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected compression goroutines to exit, %d are left", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
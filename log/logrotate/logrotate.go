@@ -17,7 +17,12 @@ package logrotate
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,27 +32,48 @@ import (
 // Logrotate is a special case of sink which writes to a file and is capable of
 // rotating that file when certain conditions are met.
 type Logrotate struct {
-	file     *os.File
-	buf      *bufio.Writer
-	filename string
-	format   string
-	interval time.Duration
-	fields   []string
-	err      chan error
-	stop     chan bool
-	mux      sync.Mutex
+	file      *os.File
+	buf       *bufio.Writer
+	filename  string
+	formatter log.Formatter
+	interval  time.Duration
+	schedule  Schedule      // replaces interval, if not nil
+	maxSize   int64         // rotate once the file would grow beyond maxSize, if > 0
+	size      int64         // bytes written to the file, including the buffer
+	watch     time.Duration // interval of checkFile, if > 0
+
+	// retention of rotated files, each is disabled if not positive.
+	maxBackups   int
+	maxAge       time.Duration
+	maxTotalSize int64
+
+	codec   Codec          // compresses rotated files, if not nil
+	pending sync.WaitGroup // rotated files not yet compressed and retained
+	pmux    sync.Mutex     // held while handling a rotated file
+
+	err  chan error
+	stop chan bool
+	mux  sync.Mutex
 }
 
 func (l *Logrotate) open() error {
 	file, err := os.OpenFile(l.filename, os.O_RDWR|os.O_APPEND, 0666)
 	if err != nil {
-		file, err = os.Create(l.filename)
+		// the file is opened for appending so writes land at its end, even if
+		// it gets truncated by someone else.
+		file, err = os.OpenFile(l.filename, os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0666)
 		if err != nil {
 			return fmt.Errorf("log: unable to open or create file %s", l.filename)
 		}
 	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
 	l.file = file
 	l.buf = bufio.NewWriter(l.file)
+	l.size = info.Size()
 	return nil
 }
 
@@ -72,6 +98,26 @@ func (l *Logrotate) reload() error {
 	return nil
 }
 
+// checkFile reopens the file if it is no longer the one at the path, or if it is
+// smaller than what was written to it.
+func (l *Logrotate) checkFile() error {
+	info, err := os.Stat(l.filename)
+	if os.IsNotExist(err) {
+		return l.reload()
+	}
+	if err != nil {
+		return err
+	}
+	current, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, current) || info.Size() < l.size-int64(l.buf.Buffered()) {
+		return l.reload()
+	}
+	return nil
+}
+
 var dateFormat = "2006-01-02T150405"
 
 // DateFormat sets the date format to be used as the extension to rotated files.
@@ -84,37 +130,193 @@ func (l *Logrotate) rotate(t time.Time) error {
 		return err
 	}
 	target := l.filename + "." + t.Format(dateFormat)
+	for i := 1; l.exists(target); i++ {
+		target = fmt.Sprintf("%s.%s.%d", l.filename, t.Format(dateFormat), i)
+	}
 	if err := os.Rename(l.filename, target); err != nil {
+		// keep logging to the file which could not be rotated.
+		l.open()
 		return err
 	}
 	if err := l.open(); err != nil {
 		return err
 	}
+	l.pending.Add(1)
+	go l.rotated(target)
 	return nil
 }
 
+// rotated compresses the rotated file name if a codec is set, then applies the
+// retention options. It runs in the background so logging is not blocked, and
+// handles rotated files one at a time so retention does not remove a file while
+// it is being compressed. Errors are reported on the channel returned by Error.
+func (l *Logrotate) rotated(name string) {
+	l.pmux.Lock()
+	var err error
+	if l.codec != nil {
+		err = compressFile(l.codec, name)
+	}
+	if rerr := l.retain(time.Now()); err == nil {
+		err = rerr
+	}
+	l.pmux.Unlock()
+	l.pending.Done()
+	if err != nil {
+		l.report(err)
+	}
+}
+
+// backup is a rotated file.
+type backup struct {
+	name string
+	time time.Time // time of the rotation
+	seq  int       // counter for rotations within the same second
+	size int64
+}
+
+// parseBackup parses the suffix added to the name of a rotated file, which is
+// the date of the rotation optionally followed by a counter and the extension
+// of the codec.
+func (l *Logrotate) parseBackup(suffix string) (time.Time, int, bool) {
+	if l.codec != nil {
+		suffix = strings.TrimSuffix(suffix, l.codec.Ext())
+	}
+	if t, err := time.ParseInLocation(dateFormat, suffix, time.Local); err == nil {
+		return t, 0, true
+	}
+	i := strings.LastIndex(suffix, ".")
+	if i < 0 {
+		return time.Time{}, 0, false
+	}
+	seq, err := strconv.Atoi(suffix[i+1:])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	t, err := time.ParseInLocation(dateFormat, suffix[:i], time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+// backups returns the rotated files found next to the log file, newest first.
+func (l *Logrotate) backups() ([]backup, error) {
+	dir, base := filepath.Split(l.filename)
+	if dir == "" {
+		dir = "."
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []backup
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		t, seq, ok := l.parseBackup(name[len(base)+1:])
+		if !ok {
+			continue
+		}
+		backups = append(backups, backup{filepath.Join(dir, name), t, seq, info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].time.Equal(backups[j].time) {
+			return backups[i].seq > backups[j].seq
+		}
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups, nil
+}
+
+// retain removes the rotated files exceeding the retention options, keeping the
+// newest ones. It returns the first error encountered.
+func (l *Logrotate) retain(now time.Time) error {
+	if l.maxBackups <= 0 && l.maxAge <= 0 && l.maxTotalSize <= 0 {
+		return nil
+	}
+	backups, err := l.backups()
+	if err != nil {
+		return err
+	}
+	var total int64
+	var first error
+	for i, b := range backups {
+		total += b.size
+		if (l.maxBackups > 0 && i >= l.maxBackups) ||
+			(l.maxAge > 0 && now.Sub(b.time) > l.maxAge) ||
+			(l.maxTotalSize > 0 && total > l.maxTotalSize) {
+			if err := os.Remove(b.name); err != nil && !os.IsNotExist(err) && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// exists reports whether a rotated file named name exists, either as is or
+// compressed.
+func (l *Logrotate) exists(name string) bool {
+	if _, err := os.Lstat(name); err == nil {
+		return true
+	}
+	if l.codec == nil {
+		return false
+	}
+	_, err := os.Lstat(name + l.codec.Ext())
+	return err == nil
+}
+
+// write writes b to the internal buffer, first rotating the file if b would
+// grow it beyond maxSize. A file is never rotated while empty, so an entry
+// larger than maxSize still gets written. If the rotation fails, the error is
+// reported on the channel returned by Error and b is written to the file which
+// could not be rotated.
+func (l *Logrotate) write(b []byte) (int, error) {
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(time.Now()); err != nil {
+			l.report(err)
+		}
+	}
+	n, err := l.buf.Write(b)
+	l.size += int64(n)
+	return n, err
+}
+
+// report sends err on the channel returned by Error, dropping it if the channel
+// is full because nobody drains it.
+func (l *Logrotate) report(err error) {
+	select {
+	case l.err <- err:
+	default:
+	}
+}
+
 // Log satisfies the log.Sink interface so it can be supplied as an argument to
-// log.New(). It writes the log to the internal buffer, using the format and
-// fields.
+// log.New(). It writes the log to the internal buffer, using the formatter.
 func (l *Logrotate) Log(fields log.Fields) {
+	l.TryLog(fields)
+}
+
+// TryLog is like Log but returns any error encountered while formatting or
+// buffering the log, satisfying the log.ErrorSink interface.
+func (l *Logrotate) TryLog(fields log.Fields) error {
+	b, err := l.formatter.Format(fields)
+	if err != nil {
+		return err
+	}
 	l.mux.Lock()
 	defer l.mux.Unlock()
-	vals := make([]interface{}, len(l.fields))
-	for i, field := range l.fields {
-		if fn, ok := fields[field]; ok {
-			vals[i] = fn()
-		} else {
-			vals[i] = "???"
-		}
-	}
-	fmt.Fprintf(l.buf, l.format, vals...)
+	_, err = l.write(b)
+	return err
 }
 
 // Write writes p to the internal buffer.
 func (l *Logrotate) Write(p []byte) (int, error) {
 	l.mux.Lock()
 	defer l.mux.Unlock()
-	return l.buf.Write(p)
+	return l.write(p)
 }
 
 // Flush empties the contents of the internal buffer to the output file.
@@ -132,6 +334,14 @@ func (l *Logrotate) Reload() error {
 	return l.reload()
 }
 
+// CheckFile reopens the file if it was renamed, deleted or truncated since it
+// was opened. It is called periodically by Run when the Watch option is used.
+func (l *Logrotate) CheckFile() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.checkFile()
+}
+
 // Rotate performs a file rotation similar to that of the logrotate(8) utility.
 func (l *Logrotate) Rotate() error {
 	l.mux.Lock()
@@ -139,24 +349,76 @@ func (l *Logrotate) Rotate() error {
 	return l.rotate(time.Now())
 }
 
-// Close flushes the internal buffer to the output file and closes the file.
+// Close flushes the internal buffer to the output file and closes the file. It
+// then waits for rotated files to be compressed and retained.
 func (l *Logrotate) Close() error {
 	l.mux.Lock()
-	defer l.mux.Unlock()
-	return l.close()
+	err := l.close()
+	l.mux.Unlock()
+	l.pending.Wait()
+	return err
+}
+
+// maxWait is the longest Run waits before checking the time of the next
+// rotation, so rotations are not delayed by the system being suspended or its
+// clock being changed.
+const maxWait = time.Minute
+
+// wait returns the duration to wait until next, at most maxWait.
+func wait(next time.Time) time.Duration {
+	d := time.Until(next)
+	if d > maxWait {
+		d = maxWait
+	}
+	return d
 }
 
-// Run will block and call Rotate or Flush on their respective intervals. If an
-// error occurs during those operations should be handled by callers using the
-// error channel returned by the Error method. This method will only return once
-// the Stop method is called.
+// Run will block and call Rotate or Flush on their respective intervals, or
+// rotate on the schedule given using Every. If an error occurs during those
+// operations should be handled by callers using the error channel returned by
+// the Error method. This method will only return once the Stop method is
+// called. If the interval is not positive and there is no schedule, files are
+// only rotated by size.
 func (l *Logrotate) Run() {
-	rotate := time.NewTicker(l.interval)
+	schedule := l.schedule
+	if schedule == nil && l.interval > 0 {
+		schedule = every(l.interval)
+	}
+	var next time.Time
+	if schedule != nil {
+		next = schedule.Next(time.Now())
+	}
+	var timer *time.Timer
+	var tick <-chan time.Time
+	if !next.IsZero() {
+		timer = time.NewTimer(wait(next))
+		defer timer.Stop()
+		tick = timer.C
+	}
+	var check <-chan time.Time
+	if l.watch > 0 {
+		watch := time.NewTicker(l.watch)
+		defer watch.Stop()
+		check = watch.C
+	}
 	flush := time.NewTicker(time.Second * 3)
+	defer flush.Stop()
 	for {
 		select {
-		case <-rotate.C:
-			if err := l.Rotate(); err != nil {
+		case now := <-tick:
+			if !now.Before(next) {
+				if err := l.Rotate(); err != nil {
+					l.err <- err
+				}
+				next = schedule.Next(now)
+			}
+			if next.IsZero() {
+				tick = nil
+			} else {
+				timer.Reset(wait(next))
+			}
+		case <-check:
+			if err := l.CheckFile(); err != nil {
 				l.err <- err
 			}
 		case <-flush.C:
@@ -164,8 +426,6 @@ func (l *Logrotate) Run() {
 				l.err <- err
 			}
 		case <-l.stop:
-			rotate.Stop()
-			flush.Stop()
 			return
 		}
 	}
@@ -188,15 +448,73 @@ func (l *Logrotate) Stop() {
 	l.stop <- true
 }
 
+// Option configures a Logrotate.
+type Option func(*Logrotate)
+
+// MaxSize rotates the file as soon as writing a log would grow it beyond n
+// bytes, in addition to rotating it on the interval.
+func MaxSize(n int64) Option {
+	return func(l *Logrotate) {
+		l.maxSize = n
+	}
+}
+
+// MaxBackups keeps at most n rotated files, removing the oldest ones after each
+// rotation.
+func MaxBackups(n int) Option {
+	return func(l *Logrotate) {
+		l.maxBackups = n
+	}
+}
+
+// MaxAge removes rotated files older than d after each rotation.
+func MaxAge(d time.Duration) Option {
+	return func(l *Logrotate) {
+		l.maxAge = d
+	}
+}
+
+// MaxTotalSize keeps the rotated files within n bytes in total, removing the
+// oldest ones after each rotation.
+func MaxTotalSize(n int64) Option {
+	return func(l *Logrotate) {
+		l.maxTotalSize = n
+	}
+}
+
+// Every rotates the file on schedule s, such as Daily(nil), instead of on the
+// interval.
+func Every(s Schedule) Option {
+	return func(l *Logrotate) {
+		l.schedule = s
+	}
+}
+
+// Watch checks every d whether the file was renamed, deleted or truncated by
+// someone else, such as logrotate(8) in copytruncate mode, and reopens it if
+// so. The file is checked by Run.
+func Watch(d time.Duration) Option {
+	return func(l *Logrotate) {
+		l.watch = d
+	}
+}
+
 // New returns a new Logrotate using the supplied arguments.
-func New(file string, interval time.Duration, format string, fields []string) (*Logrotate, error) {
+func New(file string, interval time.Duration, format string, fields []string, opts ...Option) (*Logrotate, error) {
+	return NewWithFormatter(file, interval, log.PrintfFormatter(format, fields), opts...)
+}
+
+// NewWithFormatter returns a new Logrotate which renders log messages using f.
+func NewWithFormatter(file string, interval time.Duration, f log.Formatter, opts ...Option) (*Logrotate, error) {
 	l := &Logrotate{
-		filename: file,
-		format:   format,
-		interval: interval,
-		fields:   fields,
-		err:      make(chan error),
-		stop:     make(chan bool),
+		filename:  file,
+		formatter: f,
+		interval:  interval,
+		err:       make(chan error, 16),
+		stop:      make(chan bool),
+	}
+	for _, opt := range opts {
+		opt(l)
 	}
 	return l, l.open()
 }
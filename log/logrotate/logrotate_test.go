@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"sort"
 	"testing"
 	"time"
 
@@ -111,3 +112,240 @@ func TestRun(t *testing.T) {
 		sink.Close()
 	}
 }
+
+func TestMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := New(dir+"/test.log", 0, "%s\n", []string{"message"}, MaxSize(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	logger := log.New(sink)
+	for i := 0; i < 5; i++ {
+		logger.Infof("entry %d", i) // 8 bytes, so two fit in a file.
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// rotated files must not overwrite each other, even within the same second.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(files))
+	}
+	var all []byte
+	for _, f := range files {
+		if f.Size() > 20 {
+			t.Errorf("expected %s to be at most 20 bytes, got %d", f.Name(), f.Size())
+		}
+		b, err := ioutil.ReadFile(dir + "/" + f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, b...)
+	}
+	for i := 0; i < 5; i++ {
+		if !bytes.Contains(all, []byte(fmt.Sprintf("entry %d\n", i))) {
+			t.Errorf("expected entry %d to be logged", i)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	now := time.Now()
+	for _, test := range []struct {
+		opt      Option
+		expected []string
+	}{
+		{MaxBackups(3), []string{"1h", "2h"}},
+		{MaxAge(150 * time.Minute), []string{"1h", "2h"}},
+		{MaxTotalSize(30), []string{"1h", "2h", "3h"}},
+	} {
+		dir, err := ioutil.TempDir("", "logrotate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		// rotated files of 10 bytes from 1h to 4h ago, along with unrelated files.
+		names := map[string]string{}
+		for _, d := range []string{"1h", "2h", "3h", "4h"} {
+			age, _ := time.ParseDuration(d)
+			name := dir + "/test.log." + now.Add(-age).Format(dateFormat)
+			names[name] = d
+			if err := ioutil.WriteFile(name, make([]byte, 10), 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, name := range []string{"test.log.old", "other.log." + now.Format(dateFormat)} {
+			if err := ioutil.WriteFile(dir+"/"+name, nil, 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		sink, err := New(dir+"/test.log", 0, "%s\n", []string{"message"}, test.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		sink.Close()
+
+		// the file rotated just now is empty, so it counts as a backup but not
+		// towards the total size.
+		var kept []string
+		for name, d := range names {
+			if _, err := os.Stat(name); err == nil {
+				kept = append(kept, d)
+			}
+		}
+		sort.Strings(kept)
+		if fmt.Sprint(kept) != fmt.Sprint(test.expected) {
+			t.Errorf("expected %v to be kept, got %v", test.expected, kept)
+		}
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) != len(test.expected)+4 {
+			t.Errorf("expected unrelated files to be kept, got %d files", len(files))
+		}
+	}
+}
+
+func TestRetentionUnlocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := New(dir+"/test.log", 0, "%s\n", []string{"message"}, MaxBackups(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// retention is held up, which must not block rotating or writing.
+	sink.pmux.Lock()
+	for i := 0; i < 3; i++ {
+		if _, err := sink.Write([]byte("entry\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.pmux.Unlock()
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := sink.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Errorf("expected 1 backup to be kept, got %d", len(backups))
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		change func(file string) error
+	}{
+		{"renamed", func(file string) error { return os.Rename(file, file+".1") }},
+		{"deleted", os.Remove},
+		{"truncated", func(file string) error { return os.Truncate(file, 0) }},
+	} {
+		dir, err := ioutil.TempDir("", "logrotate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		file := dir + "/test.log"
+
+		sink, err := New(file, 0, "%s\n", []string{"message"}, Watch(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := log.New(sink)
+		logger.Info("before")
+		if err := sink.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		// nothing changed yet.
+		if err := sink.CheckFile(); err != nil {
+			t.Fatal(err)
+		}
+		if err := test.change(file); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.CheckFile(); err != nil {
+			t.Fatal(err)
+		}
+		if sink.size != 0 {
+			t.Errorf("%s: expected the size used by MaxSize to be reset, got %d", test.name, sink.size)
+		}
+		logger.Info("after")
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "after\n" {
+			t.Errorf("%s: expected the file to be reopened, got %q", test.name, b)
+		}
+	}
+}
+
+func TestRotateError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := dir + "/test.log"
+
+	// the rename fails as the directory of the target does not exist.
+	defer DateFormat(dateFormat)
+	DateFormat("missing/2006-01-02T150405")
+
+	sink, err := New(file, 0, "%s\n", []string{"message"}, MaxSize(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	for _, entry := range []string{"before\n", "failed\n", "after\n"} {
+		if _, err := sink.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-sink.Error():
+	default:
+		t.Error("expected the rotation error to be reported")
+	}
+	if err := sink.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "before\nfailed\nafter\n" {
+		t.Errorf("expected logging to continue after a failed rotation, got %q", b)
+	}
+}
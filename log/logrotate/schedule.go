@@ -0,0 +1,266 @@
+package logrotate
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when files are rotated.
+type Schedule interface {
+	// Next returns the first rotation time after t, or the zero Time if there
+	// is none.
+	Next(t time.Time) time.Time
+}
+
+// every rotates at a fixed interval, relative to the previous rotation.
+type every time.Duration
+
+func (d every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+type hourly struct {
+	loc *time.Location
+}
+
+// Next returns the start of the next hour. The offset of the time zone may not
+// be a whole number of hours, so the hour is truncated in wall clock time. It
+// is then advanced in absolute time, so the repeated hour of a daylight saving
+// time change still gets its own rotation.
+func (s hourly) Next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	elapsed := time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+	return t.Add(time.Hour - elapsed)
+}
+
+type daily struct {
+	loc *time.Location
+}
+
+func (s daily) Next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+}
+
+type weekly struct {
+	day time.Weekday
+	loc *time.Location
+}
+
+func (s weekly) Next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	days := (int(s.day) - int(t.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, s.loc)
+}
+
+func location(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Hourly rotates at the start of every hour in loc, or in the local time zone
+// if loc is nil.
+func Hourly(loc *time.Location) Schedule {
+	return hourly{location(loc)}
+}
+
+// Daily rotates at midnight in loc, or in the local time zone if loc is nil. A
+// day which is shortened or lengthened by a daylight saving time change is
+// still rotated once.
+func Daily(loc *time.Location) Schedule {
+	return daily{location(loc)}
+}
+
+// Weekly rotates at midnight of the given day of the week in loc, or in the
+// local time zone if loc is nil.
+func Weekly(day time.Weekday, loc *time.Location) Schedule {
+	return weekly{day, location(loc)}
+}
+
+// cron rotates at the times matching a crontab(5) specification. Each field is
+// a set of bits, bit i being set if the value i matches.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	domAll, dowAll                bool
+	loc                           *time.Location
+}
+
+// cronFields describes the fields of a crontab specification, in order.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a crontab(5) specification made of the minute, hour, day of
+// month, month and day of week fields, such as "0 0 * * 1" for every Monday at
+// midnight. Each field is either "*" or a comma separated list of values and
+// ranges such as "1-5", optionally followed by a step such as "*/15". The
+// descriptors "@yearly", "@monthly", "@weekly", "@daily" and "@hourly" are also
+// supported. Times are matched in loc, or in the local time zone if loc is nil.
+//
+// Times which are skipped by a daylight saving time change are rotated once,
+// when the clocks move forward, and a time which is repeated is rotated once.
+func ParseCron(spec string, loc *time.Location) (Schedule, error) {
+	if d, ok := cronDescriptors[spec]; ok {
+		spec = d
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("logrotate: invalid cron spec %q, expected %d fields", spec, len(cronFields))
+	}
+	var bits [5]uint64
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("logrotate: invalid %s in cron spec %q: %s", cronFields[i].name, spec, err)
+		}
+		bits[i] = b
+	}
+	if bits[4]&(1<<7) != 0 { // 7 is also Sunday.
+		bits[4] |= 1
+	}
+	return &cron{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAll: fields[2] == "*",
+		dowAll: fields[4] == "*",
+		loc:    location(loc),
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		r, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			r = part[:i]
+		}
+		lo, hi := min, max
+		switch {
+		case r == "*":
+		case strings.Contains(r, "-"):
+			i := strings.Index(r, "-")
+			var err error
+			if lo, err = strconv.Atoi(r[:i]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", r[:i])
+			}
+			if hi, err = strconv.Atoi(r[i+1:]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", r[i+1:])
+			}
+		default:
+			var err error
+			if lo, err = strconv.Atoi(r); err != nil {
+				return 0, fmt.Errorf("invalid value %q", r)
+			}
+			if step == 1 {
+				hi = lo
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c *cron) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAll || c.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// time places the wall clock time w in the time zone. If w is skipped by a
+// daylight saving time change, the time the clocks moved forward is returned.
+func (c *cron) time(w time.Time) time.Time {
+	r := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, c.loc)
+	wall := time.Date(r.Year(), r.Month(), r.Day(), r.Hour(), r.Minute(), 0, 0, time.UTC)
+	if wall.Equal(w) {
+		return r
+	}
+	start, end := r.ZoneBounds()
+	if wall.Before(w) {
+		return end
+	}
+	return start
+}
+
+// Next walks through the wall clock times following t, represented in UTC so
+// they are not affected by daylight saving time, skipping whole months, days
+// and hours which do not match. The first match which falls after t once it is
+// placed in the time zone is returned.
+func (c *cron) Next(t time.Time) time.Time {
+	u := t.In(c.loc)
+	w := time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute()+1, 0, 0, time.UTC)
+	end := w.AddDate(5, 0, 0)
+	for w.Before(end) {
+		switch {
+		case c.month&(1<<uint(w.Month())) == 0:
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchDay(w):
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<uint(w.Hour())) == 0:
+			w = w.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<uint(w.Minute())) == 0:
+			w = w.Add(time.Minute)
+		default:
+			r := c.time(w)
+			if r.After(t) {
+				return r
+			}
+			w = w.Add(time.Minute)
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,149 @@
+package logrotate
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yieldr/go-log/log"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available: %s", name, err)
+	}
+	return loc
+}
+
+func TestSchedule(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	parse := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	cron := func(spec string) Schedule {
+		s, err := ParseCron(spec, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	for _, test := range []struct {
+		name     string
+		schedule Schedule
+		t        string
+		expected []string
+	}{
+		{"hourly", Hourly(ny), "2021-06-01T10:37:12-04:00", []string{"2021-06-01T11:00:00-04:00", "2021-06-01T12:00:00-04:00"}},
+		{"hourly, clocks go back", Hourly(ny), "2021-11-07T00:30:00-04:00", []string{"2021-11-07T01:00:00-04:00", "2021-11-07T01:00:00-05:00", "2021-11-07T02:00:00-05:00"}},
+		{"hourly, clocks go forward", Hourly(ny), "2021-03-14T01:30:00-05:00", []string{"2021-03-14T03:00:00-04:00"}},
+		{"hourly, half hour offset", Hourly(loadLocation(t, "Asia/Kolkata")), "2021-06-01T10:37:12+05:30", []string{"2021-06-01T11:00:00+05:30"}},
+		{"daily", Daily(ny), "2021-06-01T10:37:12-04:00", []string{"2021-06-02T00:00:00-04:00", "2021-06-03T00:00:00-04:00"}},
+		{"daily, clocks go back", Daily(ny), "2021-11-06T12:00:00-04:00", []string{"2021-11-07T00:00:00-04:00", "2021-11-08T00:00:00-05:00"}},
+		{"daily, from another zone", Daily(ny), "2021-06-02T02:00:00Z", []string{"2021-06-02T00:00:00-04:00"}},
+		{"weekly", Weekly(time.Monday, ny), "2021-06-01T10:37:12-04:00", []string{"2021-06-07T00:00:00-04:00", "2021-06-14T00:00:00-04:00"}},
+		{"cron", cron("*/20 9-10 * * *"), "2021-06-01T10:37:12-04:00", []string{"2021-06-01T10:40:00-04:00", "2021-06-02T09:00:00-04:00"}},
+		{"cron, day of month or week", cron("0 0 1 * 1"), "2021-06-01T10:37:12-04:00", []string{"2021-06-07T00:00:00-04:00", "2021-06-14T00:00:00-04:00", "2021-06-21T00:00:00-04:00", "2021-06-28T00:00:00-04:00", "2021-07-01T00:00:00-04:00"}},
+		{"cron, sunday as 7", cron("0 0 * * 7"), "2021-06-01T10:37:12-04:00", []string{"2021-06-06T00:00:00-04:00"}},
+		{"cron, yearly", cron("@yearly"), "2021-06-01T10:37:12-04:00", []string{"2022-01-01T00:00:00-05:00"}},
+		{"cron, leap day", cron("0 0 29 2 *"), "2021-06-01T10:37:12-04:00", []string{"2024-02-29T00:00:00-05:00"}},
+		{"cron, clocks go back", cron("30 1 * * *"), "2021-11-07T00:00:00-04:00", []string{"2021-11-07T01:30:00-04:00", "2021-11-08T01:30:00-05:00"}},
+		{"cron, clocks go forward", cron("30 2 * * *"), "2021-03-13T12:00:00-05:00", []string{"2021-03-14T03:00:00-04:00", "2021-03-15T02:30:00-04:00"}},
+		{"cron, clocks go forward, every 10 minutes", cron("*/10 2 * * *"), "2021-03-14T01:55:00-05:00", []string{"2021-03-14T03:00:00-04:00", "2021-03-15T02:00:00-04:00"}},
+	} {
+		next := parse(test.t)
+		for _, e := range test.expected {
+			next = test.schedule.Next(next)
+			if !next.Equal(parse(e)) {
+				t.Errorf("%s: expected %s, got %s", test.name, e, next.Format(time.RFC3339))
+				break
+			}
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@often",
+	} {
+		if _, err := ParseCron(spec, nil); err == nil {
+			t.Errorf("expected an error parsing %q", spec)
+		}
+	}
+	s, err := ParseCron("0 0 30 2 *", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("expected no rotation on February 30th, got %s", next)
+	}
+}
+
+type scheduleFunc func(time.Time) time.Time
+
+func (fn scheduleFunc) Next(t time.Time) time.Time { return fn(t) }
+
+func TestRunSchedule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logrotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// rotate once, 100ms from now.
+	var rotations int
+	schedule := scheduleFunc(func(t time.Time) time.Time {
+		if rotations++; rotations > 1 {
+			return time.Time{}
+		}
+		return t.Add(100 * time.Millisecond)
+	})
+	sink, err := New(dir+"/test.log", time.Millisecond, "%s\n", []string{"message"}, Every(schedule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	log.New(sink).Info("hello!")
+
+	go sink.Run()
+	time.Sleep(300 * time.Millisecond)
+	sink.Stop()
+
+	files, err := filepath.Glob(dir + "/test.log.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected the schedule to replace the interval and rotate once, got %v", files)
+	}
+}
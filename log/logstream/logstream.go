@@ -1,7 +1,6 @@
 package logstream
 
 import (
-	"fmt"
 	"sync"
 	"time"
 
@@ -10,9 +9,8 @@ import (
 
 // Logstream is a special case of sink which writes to a stream.
 type Logstream struct {
-	interval time.Duration
-	format   string
-	fields   []string
+	interval  time.Duration
+	formatter log.Formatter
 
 	errChan  chan error
 	stopChan chan bool
@@ -24,11 +22,15 @@ type Logstream struct {
 
 // New returns a new Logstream using the supplied arguments.
 func New(stream Stream, interval time.Duration, format string, fields []string) *Logstream {
+	return NewWithFormatter(stream, interval, log.PrintfFormatter(format, fields))
+}
+
+// NewWithFormatter returns a new Logstream which renders log messages using f.
+func NewWithFormatter(stream Stream, interval time.Duration, f log.Formatter) *Logstream {
 	return &Logstream{
-		interval: interval,
-		format:   format,
-		fields:   fields,
-		stream:   stream,
+		interval:  interval,
+		formatter: f,
+		stream:    stream,
 
 		errChan:  make(chan error),
 		stopChan: make(chan bool),
@@ -59,22 +61,31 @@ func (l *Logstream) Reload() error {
 }
 
 // Log satisfies the log.Sink interface so it can be supplied as an argument to
-// log.New(). It writes the log to the internal buffer, using the format and
-// fields.
+// log.New(). It writes the log to the internal buffer, using the formatter.
 func (l *Logstream) Log(fields log.Fields) {
+	l.TryLog(fields)
+}
+
+// TryLog is like Log but returns any error encountered while formatting or
+// buffering the log, satisfying the log.ErrorSink interface.
+func (l *Logstream) TryLog(fields log.Fields) error {
+	b, err := l.formatter.Format(fields)
+	if err != nil {
+		return err
+	}
+
 	l.mux.Lock()
 	defer l.mux.Unlock()
 
-	vals := make([]interface{}, len(l.fields))
-	for i, field := range l.fields {
-		if fn, ok := fields[field]; ok {
-			vals[i] = fn()
-		} else {
-			vals[i] = "???"
-		}
-	}
+	_, err = l.writer.Write(b)
+	return err
+}
 
-	fmt.Fprintf(l.writer, l.format, vals...)
+// Write writes p to the stream writer as a single record.
+func (l *Logstream) Write(p []byte) (int, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.writer.Write(p)
 }
 
 // Run is usually used as a deamon. All the buffered data is flushed periodically
@@ -19,9 +19,8 @@ func TestLogStreamLog(t *testing.T) {
 	stream.On("Put", mock.Anything).Return(new(StreamResponseMock), nil)
 
 	l := &Logstream{
-		format: log.BasicFormat,
-		fields: log.BasicFields,
-		writer: NewStreamWriter(stream),
+		formatter: log.PrintfFormatter(log.BasicFormat, log.BasicFields),
+		writer:    NewStreamWriter(stream),
 	}
 
 	fields := log.Fields{
@@ -39,12 +38,11 @@ func TestLogStreamRun(t *testing.T) {
 	stream.On("Put", mock.Anything).Return(new(StreamResponseMock), nil)
 
 	l := &Logstream{
-		interval: time.Second * 3,
-		format:   log.BasicFormat,
-		fields:   log.BasicFields,
-		errChan:  make(chan error),
-		stopChan: make(chan bool),
-		writer:   NewStreamWriter(stream),
+		interval:  time.Second * 3,
+		formatter: log.PrintfFormatter(log.BasicFormat, log.BasicFields),
+		errChan:   make(chan error),
+		stopChan:  make(chan bool),
+		writer:    NewStreamWriter(stream),
 	}
 
 	// run in background
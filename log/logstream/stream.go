@@ -51,7 +51,8 @@ func (s *StreamWriter) Write(p []byte) (n int, err error) {
 	n = len(p)
 
 	if n > 0 {
-		s.buffer = append(s.buffer, StreamRecord(p))
+		// p may be reused by the caller once Write returns, so it is copied.
+		s.buffer = append(s.buffer, StreamRecord(append([]byte(nil), p...)))
 		s.bufferSize += n
 	}
 
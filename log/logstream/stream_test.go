@@ -69,6 +69,18 @@ func TestStreamWriterWriteNoError(t *testing.T) {
 	}
 }
 
+func TestStreamWriterWriteCopies(t *testing.T) {
+	w := NewStreamWriter(new(StreamMock))
+
+	p := []byte("first")
+	w.Write(p)
+	copy(p, "later")
+	w.Write(p)
+
+	assert.Equal(t, "first", string(w.buffer[0]))
+	assert.Equal(t, "later", string(w.buffer[1]))
+}
+
 func TestStreamWriterFlushNoError(t *testing.T) {
 
 	stream := new(StreamMock)
@@ -0,0 +1,119 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ModuleLevels holds the priority threshold of named loggers. Module names are
+// hierarchical, separated by dots, so a module "a.b.c" which has no threshold
+// of its own inherits the threshold of "a.b", then "a" and finally "*". When
+// no threshold applies every priority is logged.
+//
+// ModuleLevels is safe for concurrent use, so thresholds can be changed while
+// the program is running.
+type ModuleLevels struct {
+	mux    sync.RWMutex
+	levels map[string]Priority
+}
+
+// NewModuleLevels returns a new ModuleLevels with no thresholds set.
+func NewModuleLevels() *ModuleLevels {
+	return &ModuleLevels{levels: make(map[string]Priority)}
+}
+
+// ParseModuleLevels returns a new ModuleLevels configured from spec. See Parse
+// for the format of spec.
+func ParseModuleLevels(spec string) (*ModuleLevels, error) {
+	m := NewModuleLevels()
+	return m, m.Parse(spec)
+}
+
+// Parse replaces the thresholds of m with those described by spec, a comma
+// separated list of module=priority pairs such as:
+//
+//	*=WARNING,bidder=DEBUG,bidder.cache=INFO
+//
+// The module "*" sets the default threshold. A priority without a module is
+// equivalent to *=priority. If spec is invalid, m is left unchanged.
+func (m *ModuleLevels) Parse(spec string) error {
+	levels := make(map[string]Priority)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		module, priority := "*", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			module, priority = strings.TrimSpace(entry[:i]), entry[i+1:]
+		}
+		if module == "" {
+			return fmt.Errorf("log: missing module name in %q", entry)
+		}
+		p, err := ParsePriority(priority)
+		if err != nil {
+			return err
+		}
+		levels[module] = p
+	}
+	m.mux.Lock()
+	m.levels = levels
+	m.mux.Unlock()
+	return nil
+}
+
+// Set sets the threshold of module to p.
+func (m *ModuleLevels) Set(module string, p Priority) {
+	m.mux.Lock()
+	m.levels[module] = p
+	m.mux.Unlock()
+}
+
+// Level returns the threshold of module, inherited from its closest ancestor if
+// the module has no threshold of its own.
+func (m *ModuleLevels) Level(module string) Priority {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	for module != "" {
+		if p, ok := m.levels[module]; ok {
+			return p
+		}
+		i := strings.LastIndex(module, ".")
+		if i < 0 {
+			break
+		}
+		module = module[:i]
+	}
+	if p, ok := m.levels["*"]; ok {
+		return p
+	}
+	return DEBUG
+}
+
+// String returns the thresholds of m in the format accepted by Parse.
+func (m *ModuleLevels) String() string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	entries := make([]string, 0, len(m.levels))
+	for module, p := range m.levels {
+		entries = append(entries, module+"="+p.String())
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
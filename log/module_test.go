@@ -0,0 +1,71 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestModuleLevels(t *testing.T) {
+	m, err := ParseModuleLevels("*=WARNING, bidder=DEBUG,bidder.cache=info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for module, expected := range map[string]Priority{
+		"":                 WARNING,
+		"other":            WARNING,
+		"bidder":           DEBUG,
+		"bidder.auction":   DEBUG,
+		"bidder.cache":     INFO,
+		"bidder.cache.lru": INFO,
+		"bidderx":          WARNING,
+	} {
+		if p := m.Level(module); p != expected {
+			t.Errorf("expected %s for %q, got %s", expected, module, p)
+		}
+	}
+	if s := m.String(); s != "*=WARNING,bidder.cache=INFO,bidder=DEBUG" {
+		t.Errorf("unexpected string %q", s)
+	}
+	if err := m.Parse("bidder=LOUD"); err == nil {
+		t.Error("expected an error")
+	}
+	if p := m.Level("bidder"); p != DEBUG {
+		t.Errorf("expected invalid spec to leave levels unchanged, got %s", p)
+	}
+	if p := NewModuleLevels().Level("any"); p != DEBUG {
+		t.Errorf("expected DEBUG by default, got %s", p)
+	}
+}
+
+func TestLoggerNamed(t *testing.T) {
+	var buf bytes.Buffer
+	m, _ := ParseModuleLevels("*=WARNING,bidder=DEBUG,bidder.cache=INFO")
+	logger := New(WriterSink(&buf, "%s %s\n", []string{"module", "message"})).WithOptions(Levels(m))
+	bidder := logger.Named("bidder")
+	cache := bidder.Named("cache")
+
+	logger.Info("dropped")
+	logger.Warning("root")
+	bidder.Debug("bidder")
+	cache.Debug("dropped")
+	cache.Info("cache")
+
+	expected := "??? root\nbidder bidder\nbidder.cache cache\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -15,6 +15,13 @@ package log
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Priority is used to facilitate leveled logging as defined by the syslog
 // protocol. Severity values MUST be in the range of 0 to 7 inclusive, with 7
 // being the highest or most verbose level.
@@ -45,6 +52,96 @@ var priorities = map[Priority]string{
 	DEBUG:     "DEBUG",
 }
 
+// aliases are alternative names accepted by ParsePriority.
+var aliases = map[string]Priority{
+	"EMERG": EMERGENCY,
+	"CRIT":  CRITICAL,
+	"ERR":   ERROR,
+	"WARN":  WARNING,
+	"TRACE": DEBUG,
+}
+
+func (p Priority) valid() bool {
+	return p >= EMERGENCY && p <= DEBUG
+}
+
+// String returns the name of p, or "Priority(n)" if p is out of range.
 func (p Priority) String() string {
-	return priorities[p]
+	if name, ok := priorities[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// ParsePriority returns the Priority named by s. The name is case insensitive
+// and may be one of the priority names, such as "WARNING", one of the aliases
+// "emerg", "crit", "err", "warn" or "trace", or the numeric value of a priority
+// between 0 and 7.
+func ParsePriority(s string) (Priority, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	for p, n := range priorities {
+		if name == n {
+			return p, nil
+		}
+	}
+	if p, ok := aliases[name]; ok {
+		return p, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if p := Priority(n); p.valid() {
+			return p, nil
+		}
+		return 0, fmt.Errorf("log: priority %d out of range [%d, %d]", n, EMERGENCY, DEBUG)
+	}
+	return 0, fmt.Errorf("log: invalid priority %q", s)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface. It returns an
+// error if p is out of range.
+func (p Priority) MarshalText() ([]byte, error) {
+	if !p.valid() {
+		return nil, fmt.Errorf("log: priority %d out of range [%d, %d]", int(p), EMERGENCY, DEBUG)
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface, using
+// ParsePriority.
+func (p *Priority) UnmarshalText(b []byte) error {
+	v, err := ParsePriority(string(b))
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface. Priorities are encoded
+// as their name, e.g. "INFO".
+func (p Priority) MarshalJSON() ([]byte, error) {
+	b, err := p.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(b))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It accepts a string
+// understood by ParsePriority as well as a number.
+func (p *Priority) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("log: invalid priority %s", b)
+		}
+		s = strconv.Itoa(n)
+	}
+	return p.UnmarshalText([]byte(s))
+}
+
+// Set implements the flag.Value interface, so a Priority can be used with
+// flag.Var.
+func (p *Priority) Set(s string) error {
+	return p.UnmarshalText([]byte(s))
 }
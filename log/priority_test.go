@@ -15,6 +15,8 @@ package log
 // limitations under the License.
 
 import (
+	"encoding/json"
+	"flag"
 	"testing"
 )
 
@@ -24,3 +26,73 @@ func TestPriorityString(t *testing.T) {
 		t.Error("wrong mapping")
 	}
 }
+
+func TestPriorityStringOutOfRange(t *testing.T) {
+	if s := Priority(9).String(); s != "Priority(9)" {
+		t.Errorf("unexpected string %q", s)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for s, expected := range map[string]Priority{
+		"EMERGENCY": EMERGENCY,
+		"emerg":     EMERGENCY,
+		"Alert":     ALERT,
+		"crit":      CRITICAL,
+		"err":       ERROR,
+		"warn":      WARNING,
+		" notice ":  NOTICE,
+		"6":         INFO,
+		"trace":     DEBUG,
+	} {
+		p, err := ParsePriority(s)
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %s", s, err)
+		} else if p != expected {
+			t.Errorf("expected %q to parse as %s, got %s", s, expected, p)
+		}
+	}
+	for _, s := range []string{"", "loud", "8", "-1"} {
+		if _, err := ParsePriority(s); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestPriorityJSON(t *testing.T) {
+	var config struct {
+		Level Priority `json:"level"`
+		Other Priority `json:"other"`
+	}
+	if err := json.Unmarshal([]byte(`{"level": "warn", "other": 7}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Level != WARNING || config.Other != DEBUG {
+		t.Errorf("unexpected priorities %s, %s", config.Level, config.Other)
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"level":"WARNING","other":"DEBUG"}` {
+		t.Errorf("unexpected json %s", b)
+	}
+	if _, err := json.Marshal(Priority(12)); err == nil {
+		t.Error("expected an error marshaling an out of range priority")
+	}
+	if err := json.Unmarshal([]byte(`12`), &config.Level); err == nil {
+		t.Error("expected an error unmarshaling an out of range priority")
+	}
+}
+
+func TestPriorityFlag(t *testing.T) {
+	p := INFO
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&p, "level", "log level")
+	if err := fs.Parse([]string{"-level", "crit"}); err != nil {
+		t.Fatal(err)
+	}
+	if p != CRITICAL {
+		t.Errorf("expected CRITICAL, got %s", p)
+	}
+}
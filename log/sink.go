@@ -16,7 +16,6 @@ package log
 // limitations under the License.
 
 import (
-	"fmt"
 	"io"
 	"sync"
 )
@@ -27,6 +26,32 @@ type Sink interface {
 	Log(Fields)
 }
 
+// Flusher is implemented by sinks which buffer log entries, such as the file and
+// stream sinks. Flush writes any buffered entries to their destination.
+type Flusher interface {
+	Flush() error
+}
+
+// flushSink flushes s if it implements Flusher.
+func flushSink(s Sink) error {
+	if f, ok := s.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// closeSink flushes s if it implements Flusher, then closes it if it implements
+// io.Closer.
+func closeSink(s Sink) error {
+	err := flushSink(s)
+	if c, ok := s.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
 type nilSink struct{}
 
 func (sink *nilSink) Log(fields Fields) {}
@@ -35,51 +60,90 @@ func (sink *nilSink) Log(fields Fields) {}
 func NilSink() Sink { return &nilSink{} }
 
 type writerSink struct {
-	writer io.Writer
-	format string
-	fields []string
-	mux    sync.Mutex
+	writer    io.Writer
+	formatter Formatter
+	mux       sync.Mutex
 }
 
 func (sink *writerSink) Log(fields Fields) {
+	sink.TryLog(fields)
+}
+
+func (sink *writerSink) TryLog(fields Fields) error {
+	b, err := sink.formatter.Format(fields)
+	if err != nil {
+		return err
+	}
 	sink.mux.Lock()
 	defer sink.mux.Unlock()
-	vals := make([]interface{}, len(sink.fields))
-	for i, field := range sink.fields {
-		if fn, ok := fields[field]; ok {
-			vals[i] = fn()
-		} else {
-			vals[i] = "???"
-		}
+	_, err = sink.writer.Write(b)
+	return err
+}
+
+// Flush flushes the writer if it implements Flusher. The writer is never closed
+// by the sink, as it is owned by the caller.
+func (sink *writerSink) Flush() error {
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	if f, ok := sink.writer.(Flusher); ok {
+		return f.Flush()
 	}
-	fmt.Fprintf(sink.writer, sink.format, vals...)
+	return nil
 }
 
 // WriterSink creates a new sink that writes log messages to w.
 func WriterSink(w io.Writer, format string, fields []string) Sink {
+	return FormatSink(w, PrintfFormatter(format, fields))
+}
+
+// FormatSink creates a new sink that writes log messages to w, rendered by f.
+func FormatSink(w io.Writer, f Formatter) Sink {
 	return &writerSink{
-		writer: w,
-		format: format,
-		fields: fields,
+		writer:    w,
+		formatter: f,
 	}
 }
 
 type filter struct {
-	priority Priority
-	target   Sink
+	level  Leveler
+	target Sink
 }
 
 func (f *filter) Log(fields Fields) {
-	if fields["priority"]().(Priority) <= f.priority {
+	if fields["priority"]().(Priority) <= f.level.Level() {
 		f.target.Log(fields)
 	}
 }
 
+func (f *filter) TryLog(fields Fields) error {
+	if fields["priority"]().(Priority) > f.level.Level() {
+		return nil
+	}
+	if s, ok := f.target.(ErrorSink); ok {
+		return s.TryLog(fields)
+	}
+	f.target.Log(fields)
+	return nil
+}
+
+func (f *filter) Flush() error {
+	return flushSink(f.target)
+}
+
+func (f *filter) Close() error {
+	if c, ok := f.target.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 // Filter wraps the sink with leveled logging. A sink wrapped with this method
-// will ony write if the priority is equal to or less than p.
-func Filter(p Priority, s Sink) Sink {
+// will ony write if the priority is equal to or less than p. If p is a
+// *LevelVar, its current value is consulted for every log entry so the
+// threshold can be changed at runtime.
+func Filter(p Leveler, s Sink) Sink {
 	return &filter{
-		priority: p,
-		target:   s,
+		level:  p,
+		target: s,
 	}
 }
@@ -0,0 +1,245 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// Levels of the log/slog package for the priorities which it does not define.
+// Together with slog.LevelDebug, slog.LevelInfo, slog.LevelWarn and
+// slog.LevelError they cover all eight priorities.
+const (
+	LevelNotice    = slog.Level(2)
+	LevelCritical  = slog.Level(12)
+	LevelAlert     = slog.Level(16)
+	LevelEmergency = slog.Level(20)
+)
+
+// slogPriority maps a slog level onto the priority whose level is the closest
+// one at or below it, e.g. slog.LevelWarn+1 is a WARNING.
+func slogPriority(l slog.Level) Priority {
+	switch {
+	case l >= LevelEmergency:
+		return EMERGENCY
+	case l >= LevelAlert:
+		return ALERT
+	case l >= LevelCritical:
+		return CRITICAL
+	case l >= slog.LevelError:
+		return ERROR
+	case l >= slog.LevelWarn:
+		return WARNING
+	case l >= LevelNotice:
+		return NOTICE
+	case l >= slog.LevelInfo:
+		return INFO
+	}
+	return DEBUG
+}
+
+// slogLevel maps a priority onto a slog level. Priorities out of range are
+// mapped onto slog.LevelError.
+func slogLevel(p Priority) slog.Level {
+	switch p {
+	case EMERGENCY:
+		return LevelEmergency
+	case ALERT:
+		return LevelAlert
+	case CRITICAL:
+		return LevelCritical
+	case WARNING:
+		return slog.LevelWarn
+	case NOTICE:
+		return LevelNotice
+	case INFO:
+		return slog.LevelInfo
+	case DEBUG:
+		return slog.LevelDebug
+	}
+	return slog.LevelError
+}
+
+// slogAttr is an attribute of a slogHandler, flattened into a field.
+type slogAttr struct {
+	key   string
+	value interface{}
+}
+
+type slogHandler struct {
+	sink   Sink
+	logger *logger    // provides start_time, seq, pid and prefix
+	attrs  []slogAttr // attributes added using WithAttrs
+	group  string     // prefix of the keys of attributes, e.g. "a.b."
+}
+
+// SlogHandler returns a slog.Handler which passes records to s, so that code
+// using the log/slog package can write to any sink. The record is converted to
+// the same fields a Logger would provide. Attributes become fields of their
+// own, with the keys of attributes in groups prefixed by the group names, e.g.
+// "request.method". Attributes can not override the fields set by the handler
+// such as "message" or "time".
+//
+// Levels are mapped onto priorities, using LevelNotice, LevelCritical,
+// LevelAlert and LevelEmergency for the priorities slog does not define.
+func SlogHandler(s Sink) slog.Handler {
+	return &slogHandler{
+		sink:   s,
+		logger: New().(*logger),
+	}
+}
+
+// Enabled reports whether the sink would log a record of level l. Only a sink
+// created by Filter is able to reject a record before it is built.
+func (h *slogHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	if f, ok := h.sink.(*filter); ok {
+		return slogPriority(l) <= f.level.Level()
+	}
+	return true
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	logger := h.logger
+	caller := &caller{pcs: []uintptr{r.PC}}
+	p := slogPriority(r.Level)
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	fields := make(Fields, len(h.attrs)+r.NumAttrs()+12)
+	for key, value := range contextFields(ctx) {
+		fields[key] = valueFn(value)
+	}
+	for _, attr := range h.attrs {
+		fields[attr.key] = valueFn(attr.value)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		flattenAttr(fields, h.group, a)
+		return true
+	})
+	fields["priority"] = valueFn(p)
+	fields["message"] = valueFn(r.Message)
+	fields["prefix"] = logger.prefixFn
+	fields["time"] = timeFn(t)
+	fields["full_time"] = valueFn(t)
+	fields["start_time"] = logger.createdFn
+	fields["elapsed_time"] = func() interface{} { return t.Sub(logger.created) }
+	fields["seq"] = logger.seqFn
+	fields["pid"] = logger.pidFn
+	fields["file"] = caller.fileFn
+	fields["line"] = caller.lineFn
+	fields["func"] = caller.funcFn
+	fields["caller"] = caller.callerFn
+	h.sink.Log(fields)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make(Fields, len(attrs))
+	for _, a := range attrs {
+		flattenAttr(fields, h.group, a)
+	}
+	child := *h
+	child.attrs = append(make([]slogAttr, 0, len(h.attrs)+len(fields)), h.attrs...)
+	for key, fn := range fields {
+		child.attrs = append(child.attrs, slogAttr{key, fn()})
+	}
+	return &child
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	child := *h
+	child.group = h.group + name + "."
+	return &child
+}
+
+// flattenAttr adds a to fields, prefixing its key with group. The attributes of
+// a group are added individually, prefixed with the name of the group.
+func flattenAttr(fields Fields, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, child := range a.Value.Group() {
+			flattenAttr(fields, group, child)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	fields[group+a.Key] = valueFn(a.Value.Any())
+}
+
+type slogSink struct {
+	handler slog.Handler
+}
+
+func (sink *slogSink) Log(fields Fields) {
+	ctx := context.Background()
+	p, _ := fields["priority"]().(Priority)
+	level := slogLevel(p)
+	if !sink.handler.Enabled(ctx, level) {
+		return
+	}
+	var message string
+	if fn, ok := fields["message"]; ok {
+		message = fmt.Sprint(fn())
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		switch key {
+		case "priority", "message", "time", "full_time":
+		default:
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var t time.Time
+	if fn, ok := fields["full_time"]; ok {
+		t, _ = fn().(time.Time)
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+	r := slog.NewRecord(t, level, message, 0)
+	for _, key := range keys {
+		r.AddAttrs(slog.Any(key, fields[key]()))
+	}
+	sink.handler.Handle(ctx, r)
+}
+
+// SlogSink returns a sink which forwards every log entry to h, so that a Logger
+// can write to handlers of the log/slog package. Priorities are mapped onto
+// slog levels, using LevelNotice, LevelCritical, LevelAlert and LevelEmergency
+// for the priorities slog does not define. The "message" field becomes the
+// message of the record and every other field becomes an attribute, except for
+// "priority", "time" and "full_time" which are represented by the level and
+// time of the record.
+func SlogSink(h slog.Handler) Sink {
+	return &slogSink{h}
+}
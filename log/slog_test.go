@@ -0,0 +1,145 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSlogPriority(t *testing.T) {
+	for level, expected := range map[slog.Level]Priority{
+		slog.LevelDebug - 4: DEBUG,
+		slog.LevelDebug:     DEBUG,
+		slog.LevelInfo:      INFO,
+		LevelNotice:         NOTICE,
+		slog.LevelWarn:      WARNING,
+		slog.LevelWarn + 1:  WARNING,
+		slog.LevelError:     ERROR,
+		LevelCritical:       CRITICAL,
+		LevelAlert:          ALERT,
+		LevelEmergency:      EMERGENCY,
+		LevelEmergency + 8:  EMERGENCY,
+	} {
+		if p := slogPriority(level); p != expected {
+			t.Errorf("expected %s for %s, got %s", expected, level, p)
+		}
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	sink := FormatSink(&buf, LogfmtFormatter([]string{"priority", "message", "caller", "a", "g.b", "g.c", "g.h.d", "seq"}))
+	logger := slog.New(SlogHandler(Filter(INFO, sink)))
+
+	logger.With("a", 1).WithGroup("g").With("b", "two").Info("hello world", "c", 3, slog.Group("h", "d", 4), "message", "ignored")
+	logger.Debug("dropped")
+	line := line() - 2
+
+	expected := `priority=INFO message="hello world" caller=slog_test.go:` + strconv.Itoa(line) + ` a=1 g.b=two g.c=3 g.h.d=4 seq=1`
+	if got := buf.String(); len(got) < len(expected) || got[:len(expected)] != expected {
+		t.Errorf("expected output to start with %q, got %q", expected, got)
+	}
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug to be disabled by the filter")
+	}
+}
+
+func TestSlogSink(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	logger := New(SlogSink(h)).With("request_id", "abc")
+
+	for _, test := range []struct {
+		log   func(...interface{})
+		level string
+	}{
+		{logger.Emergency, "ERROR+12"},
+		{logger.Alert, "ERROR+8"},
+		{logger.Critical, "ERROR+4"},
+		{logger.Error, "ERROR"},
+		{logger.Warning, "WARN"},
+		{logger.Notice, "INFO+2"},
+		{logger.Info, "INFO"},
+		{logger.Debug, "DEBUG"},
+	} {
+		buf.Reset()
+		test.log("hello")
+
+		var record map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record["level"] != test.level {
+			t.Errorf("expected level %s, got %v", test.level, record["level"])
+		}
+		if record["msg"] != "hello" {
+			t.Errorf("expected msg hello, got %v", record["msg"])
+		}
+		if record["request_id"] != "abc" {
+			t.Errorf("expected request_id abc, got %v", record["request_id"])
+		}
+		if _, ok := record["seq"]; !ok {
+			t.Error("expected seq attribute")
+		}
+		if _, ok := record["priority"]; ok {
+			t.Error("unexpected priority attribute")
+		}
+	}
+}
+
+func TestSlogSinkTime(t *testing.T) {
+	var record slog.Record
+	sink := SlogSink(recordHandler{&record})
+
+	created := time.Date(2014, 7, 1, 12, 30, 15, 123456789, time.UTC)
+	sink.Log(Fields{
+		"priority":  valueFn(INFO),
+		"message":   valueFn("hello"),
+		"time":      valueFn(created.Format(time.Stamp)),
+		"full_time": valueFn(created),
+	})
+
+	if !record.Time.Equal(created) {
+		t.Errorf("expected record time %s, got %s", created, record.Time)
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		if a.Key == "time" || a.Key == "full_time" {
+			t.Errorf("unexpected %s attribute", a.Key)
+		}
+		return true
+	})
+}
+
+// recordHandler stores the last record it handles.
+type recordHandler struct {
+	record *slog.Record
+}
+
+func (h recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h recordHandler) Handle(_ context.Context, r slog.Record) error {
+	*h.record = r
+	return nil
+}
+
+func (h recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h recordHandler) WithGroup(string) slog.Handler { return h }
@@ -0,0 +1,84 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"io"
+	stdlog "log"
+	"sync"
+)
+
+type stdWriter struct {
+	logger   Logger
+	priority Priority
+	buf      []byte // incomplete line
+	mux      sync.Mutex
+}
+
+// Write logs every complete line in p as a separate log entry. An incomplete
+// line is kept until the rest of it is written.
+func (w *stdWriter) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := bytes.TrimSuffix(w.buf[:i], []byte{'\r'})
+		w.logger.Log(w.priority, string(line))
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// NewStdWriter returns an io.Writer which splits what is written to it into
+// lines and logs every line to l with priority p. It can be used to capture
+// the output of code which writes to an io.Writer.
+func NewStdWriter(l Logger, p Priority) io.Writer {
+	return &stdWriter{
+		logger:   l.WithOptions(AddCallerSkip(1)),
+		priority: p,
+	}
+}
+
+// RedirectStdLog redirects the output of the standard library's log package to
+// l, logging every line with priority p. The flags and prefix of the standard
+// logger are cleared, so that lines don't include a timestamp of their own.
+// The returned function restores the previous output, flags and prefix.
+//
+// The caller fields point to the first caller outside of the standard
+// library's log package, regardless of the calldepth passed to log.Output.
+func RedirectStdLog(l Logger, p Priority) func() {
+	flags, prefix, output := stdlog.Flags(), stdlog.Prefix(), stdlog.Writer()
+	stdlog.SetFlags(0)
+	stdlog.SetPrefix("")
+	stdlog.SetOutput(&stdWriter{
+		// Skip Write, then the functions of the standard library, which take
+		// up a different number of frames for Print and Output.
+		logger:   l.WithOptions(AddCallerSkip(1), skipPackage("log")),
+		priority: p,
+	})
+	return func() {
+		stdlog.SetFlags(flags)
+		stdlog.SetPrefix(prefix)
+		stdlog.SetOutput(output)
+	}
+}
@@ -0,0 +1,55 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"bytes"
+	"fmt"
+	stdlog "log"
+	"testing"
+)
+
+func TestStdWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewStdWriter(New(WriterSink(&buf, SyslogFormat, SyslogFields)), WARNING)
+
+	fmt.Fprint(w, "first\nsec")
+	fmt.Fprint(w, "ond\r\nthird")
+
+	expected := "[WARNING] first\n[WARNING] second\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	var buf bytes.Buffer
+	restore := RedirectStdLog(New(WriterSink(&buf, "%s [%s] %s\n", []string{"caller", "priority", "message"})), NOTICE)
+	stdlog.Println("hello from stdlog")
+	line := line() - 1
+	stdlog.Output(1, "hello from Output")
+	stdlog.Default().Output(1, "hello from Logger.Output")
+	restore()
+
+	expected := fmt.Sprintf("stdlog_test.go:%d [NOTICE] hello from stdlog\n", line) +
+		fmt.Sprintf("stdlog_test.go:%d [NOTICE] hello from Output\n", line+2) +
+		fmt.Sprintf("stdlog_test.go:%d [NOTICE] hello from Logger.Output\n", line+3)
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	if stdlog.Flags() != stdlog.LstdFlags {
+		t.Errorf("expected flags to be restored")
+	}
+}
@@ -17,47 +17,44 @@ package log
 // limitations under the License.
 
 import (
-	"fmt"
 	"log/syslog"
 )
 
 type syslogSink struct {
-	w        *syslog.Writer
-	priority Priority
-	format   string
-	fields   []string
+	w         *syslog.Writer
+	priority  Priority
+	formatter Formatter
 }
 
 func (sink *syslogSink) Log(fields Fields) {
-	vals := make([]interface{}, len(sink.fields))
-	for i, field := range sink.fields {
-		if fn, ok := fields[field]; ok {
-			vals[i] = fn()
-		} else {
-			vals[i] = "???"
-		}
+	sink.TryLog(fields)
+}
+
+func (sink *syslogSink) TryLog(fields Fields) error {
+	b, err := sink.formatter.Format(fields)
+	if err != nil {
+		return err
 	}
-	msg := fmt.Sprintf(sink.format, vals...)
+	msg := string(b)
 	switch fields["priority"]().(Priority) {
 	case EMERGENCY:
-		sink.w.Emerg(msg)
+		return sink.w.Emerg(msg)
 	case ALERT:
-		sink.w.Alert(msg)
+		return sink.w.Alert(msg)
 	case CRITICAL:
-		sink.w.Crit(msg)
+		return sink.w.Crit(msg)
 	case ERROR:
-		sink.w.Err(msg)
+		return sink.w.Err(msg)
 	case WARNING:
-		sink.w.Warning(msg)
+		return sink.w.Warning(msg)
 	case NOTICE:
-		sink.w.Notice(msg)
+		return sink.w.Notice(msg)
 	case INFO:
-		sink.w.Info(msg)
+		return sink.w.Info(msg)
 	case DEBUG:
-		sink.w.Debug(msg)
+		return sink.w.Debug(msg)
 	default:
-		sink.w.Err(msg)
-
+		return sink.w.Err(msg)
 	}
 }
 
@@ -71,10 +68,16 @@ func (sink *syslogSink) Close() error {
 
 // SyslogSink returns a sink that outputs to the local syslog daemon.
 func SyslogSink(p Priority, tag, format string, fields []string) (*syslogSink, error) {
+	return SyslogFormatSink(p, tag, PrintfFormatter(format, fields))
+}
+
+// SyslogFormatSink returns a sink that outputs to the local syslog daemon, with
+// messages rendered by f.
+func SyslogFormatSink(p Priority, tag string, f Formatter) (*syslogSink, error) {
 	prio := syslog.Priority(p) | syslog.LOG_USER
 	w, err := syslog.New(prio, tag)
 	if err != nil {
 		return nil, err
 	}
-	return &syslogSink{w, p, format, fields}, nil
+	return &syslogSink{w, p, f}, nil
 }
@@ -0,0 +1,219 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrorSink is implemented by sinks which are able to report a failure to log
+// an entry. Tee uses it to pass those errors on to its ErrorHandler.
+type ErrorSink interface {
+	Sink
+	TryLog(Fields) error
+}
+
+// ErrorHandler is called by Tee with the sink which failed and the error. It is
+// called concurrently, from the goroutine of each sink as well as from the
+// goroutine logging an entry, so it must be safe for concurrent use.
+type ErrorHandler func(Sink, error)
+
+// ErrDropped is reported when an entry is discarded because the queue of a sink
+// is full.
+var ErrDropped = errors.New("log: sink queue is full, entry dropped")
+
+// teeQueueSize is the number of entries a sink passed to Tee can fall behind
+// before new entries are dropped.
+var teeQueueSize = 1024
+
+func stderrHandler(s Sink, err error) {
+	fmt.Fprintf(os.Stderr, "log: %T: %s\n", s, err)
+}
+
+// trySink logs fields to s, returning any error it reports or any panic it
+// raises.
+func trySink(s Sink, fields Fields) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("log: sink panic: %v", r)
+		}
+	}()
+	if es, ok := s.(ErrorSink); ok {
+		return es.TryLog(fields)
+	}
+	s.Log(fields)
+	return nil
+}
+
+// tryFlush flushes s, returning any panic it raises as an error.
+func tryFlush(s Sink) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("log: sink panic: %v", r)
+		}
+	}()
+	return flushSink(s)
+}
+
+// snapshotFields are evaluated before an entry is handed to the sinks, as their
+// values depend on when or how many times they are evaluated. The other fields
+// are left to the sinks which use them.
+var snapshotFields = []string{"seq", "time", "elapsed_time", "message"}
+
+// snapshot copies fields, evaluating the snapshotFields so every sink sees the
+// same values. A panic raised by a field is returned as an error.
+func snapshot(fields Fields) (s Fields, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("log: field panic: %v", r)
+		}
+	}()
+	s = make(Fields, len(fields))
+	for k, fn := range fields {
+		s[k] = fn
+	}
+	for _, k := range snapshotFields {
+		if fn, ok := fields[k]; ok {
+			s[k] = valueFn(fn())
+		}
+	}
+	return s, nil
+}
+
+// teeEntry is either an entry to log or, if flush is not nil, a request to flush
+// the sink once every entry queued before it is logged.
+type teeEntry struct {
+	fields Fields
+	flush  chan error
+}
+
+type tee struct {
+	sinks   []Sink
+	queues  []chan teeEntry
+	handler ErrorHandler
+	closed  bool
+	wg      sync.WaitGroup
+	mux     sync.RWMutex
+}
+
+func (t *tee) run(s Sink, queue chan teeEntry) {
+	defer t.wg.Done()
+	for e := range queue {
+		if e.flush != nil {
+			e.flush <- tryFlush(s)
+			continue
+		}
+		if err := trySink(s, e.fields); err != nil {
+			t.handler(s, err)
+		}
+	}
+}
+
+func (t *tee) Log(fields Fields) {
+	fields, err := snapshot(fields)
+	if err != nil {
+		t.handler(t, err)
+		return
+	}
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.closed {
+		return
+	}
+	for i, queue := range t.queues {
+		select {
+		case queue <- teeEntry{fields: fields}:
+		default:
+			t.handler(t.sinks[i], ErrDropped)
+		}
+	}
+}
+
+// Flush waits for the entries queued so far to be logged, then flushes every
+// sink implementing Flusher. It returns the first error encountered.
+func (t *tee) Flush() error {
+	t.mux.RLock()
+	if t.closed {
+		t.mux.RUnlock()
+		return nil
+	}
+	done := make([]chan error, len(t.queues))
+	for i, queue := range t.queues {
+		done[i] = make(chan error, 1)
+		queue <- teeEntry{flush: done[i]}
+	}
+	t.mux.RUnlock()
+
+	var first error
+	for _, c := range done {
+		if err := <-c; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Close logs the entries queued so far, then flushes and closes every sink. It
+// returns the first error encountered.
+func (t *tee) Close() error {
+	t.mux.Lock()
+	if t.closed {
+		t.mux.Unlock()
+		return nil
+	}
+	t.closed = true
+	for _, queue := range t.queues {
+		close(queue)
+	}
+	t.mux.Unlock()
+	t.wg.Wait()
+
+	var first error
+	for _, s := range t.sinks {
+		if err := closeSink(s); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Tee returns a sink which forwards every entry to each of sinks. Every sink
+// logs from its own goroutine, so a slow sink does not hold up the logger or the
+// other sinks, and a panic in one sink is recovered. Entries are dropped if a
+// sink falls too far behind.
+//
+// Errors reported by sinks implementing ErrorSink, recovered panics and dropped
+// entries are passed to h. If h is nil, they are written to standard error. An
+// entry whose message panics is not logged, and the panic is passed to h along
+// with the sink returned by Tee.
+func Tee(h ErrorHandler, sinks ...Sink) Sink {
+	if h == nil {
+		h = stderrHandler
+	}
+	t := &tee{
+		sinks:   sinks,
+		queues:  make([]chan teeEntry, len(sinks)),
+		handler: h,
+	}
+	for i, s := range sinks {
+		t.queues[i] = make(chan teeEntry, teeQueueSize)
+		t.wg.Add(1)
+		go t.run(s, t.queues[i])
+	}
+	return t
+}
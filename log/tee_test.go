@@ -0,0 +1,138 @@
+package log
+
+// Copyright 2013 CoreOS, Inc.
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type errSink struct {
+	err error
+}
+
+func (s errSink) Log(Fields) {}
+
+func (s errSink) TryLog(Fields) error { return s.err }
+
+type handlerRecorder struct {
+	errs []error
+	mux  sync.Mutex
+}
+
+func (r *handlerRecorder) handle(s Sink, err error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+func TestTee(t *testing.T) {
+	var a, b bytes.Buffer
+	var r handlerRecorder
+	failed := errors.New("failed")
+	sink := Tee(r.handle,
+		WriterSink(&a, "%d %s\n", []string{"seq", "message"}),
+		sinkFunc(func(Fields) { panic("oops") }),
+		errSink{failed},
+		WriterSink(&b, "%d %s\n", []string{"seq", "message"}))
+
+	l := New(sink)
+	l.Info("one")
+	l.Info("two")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "1 one\n2 two\n"
+	if a.String() != expected || b.String() != expected {
+		t.Errorf("expected %q in both sinks, got %q and %q", expected, a.String(), b.String())
+	}
+	if len(r.errs) != 4 {
+		t.Fatalf("expected 4 errors, got %v", r.errs)
+	}
+	var panics, fails int
+	for _, err := range r.errs {
+		switch {
+		case err == failed:
+			fails++
+		case err.Error() == "log: sink panic: oops":
+			panics++
+		}
+	}
+	if panics != 2 || fails != 2 {
+		t.Errorf("expected 2 panics and 2 failures, got %v", r.errs)
+	}
+}
+
+func TestTeeDropped(t *testing.T) {
+	defer func(n int) { teeQueueSize = n }(teeQueueSize)
+	teeQueueSize = 1
+
+	var r handlerRecorder
+	block := make(chan bool)
+	sink := Tee(r.handle, sinkFunc(func(Fields) { <-block }))
+
+	// At most one entry is being logged by the blocked sink and one is queued,
+	// so the third is dropped without blocking the logger.
+	l := New(sink)
+	l.Info("one")
+	l.Info("two")
+	l.Info("three")
+	close(block)
+	if err := l.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if len(r.errs) == 0 || r.errs[0] != ErrDropped {
+		t.Errorf("expected ErrDropped, got %v", r.errs)
+	}
+}
+
+func TestTeeLazyFields(t *testing.T) {
+	var r handlerRecorder
+	var buf bytes.Buffer
+	sink := Tee(r.handle, WriterSink(&buf, "%s\n", []string{"message"}))
+
+	var evaluated int32
+	sink.Log(Fields{
+		"priority": func() interface{} { return INFO },
+		"message":  func() interface{} { return "hello!" },
+		"caller":   func() interface{} { atomic.AddInt32(&evaluated, 1); return "main.go:1" },
+	})
+	sink.Log(Fields{
+		"priority": func() interface{} { return INFO },
+		"message":  func() interface{} { panic("oops") },
+	})
+	if err := sink.(Flusher).Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt32(&evaluated) != 0 {
+		t.Error("expected fields not used by any sink to be left unevaluated")
+	}
+	if buf.String() != "hello!\n" {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if len(r.errs) != 1 || r.errs[0].Error() != "log: field panic: oops" {
+		t.Errorf("expected the panic of the message to be reported, got %v", r.errs)
+	}
+}
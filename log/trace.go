@@ -0,0 +1,146 @@
+package log
+
+// Copyright 2014 Yieldr
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SpanContext identifies a span of a distributed trace, as carried by the W3C
+// traceparent header.
+//
+// See https://www.w3.org/TR/trace-context/
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// TraceIDString returns the trace id as a lowercase hex string.
+func (sc SpanContext) TraceIDString() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+// SpanIDString returns the span id as a lowercase hex string.
+func (sc SpanContext) SpanIDString() string {
+	return hex.EncodeToString(sc.SpanID[:])
+}
+
+// FlagsString returns the trace flags as a two character hex string.
+func (sc SpanContext) FlagsString() string {
+	return hex.EncodeToString([]byte{sc.Flags})
+}
+
+// String returns sc formatted as a version 00 traceparent header.
+func (sc SpanContext) String() string {
+	return "00-" + sc.TraceIDString() + "-" + sc.SpanIDString() + "-" + sc.FlagsString()
+}
+
+// ParseTraceparent parses the value of a W3C traceparent header, such as:
+//
+//	00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("log: invalid traceparent %q", s)
+	}
+	version, err := decodeHex(parts[0], 1)
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return sc, fmt.Errorf("log: invalid traceparent version in %q", s)
+	}
+	traceID, err := decodeHex(parts[1], len(sc.TraceID))
+	if err != nil || isZero(traceID) {
+		return sc, fmt.Errorf("log: invalid trace id in %q", s)
+	}
+	spanID, err := decodeHex(parts[2], len(sc.SpanID))
+	if err != nil || isZero(spanID) {
+		return sc, fmt.Errorf("log: invalid span id in %q", s)
+	}
+	flags, err := decodeHex(parts[3], 1)
+	if err != nil {
+		return sc, fmt.Errorf("log: invalid trace flags in %q", s)
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	return sc, nil
+}
+
+// decodeHex decodes s, which must be n bytes of lowercase hex.
+func decodeHex(s string, n int) ([]byte, error) {
+	if len(s) != n*2 || strings.ToLower(s) != s {
+		return nil, fmt.Errorf("log: expected %d lowercase hex characters", n*2)
+	}
+	return hex.DecodeString(s)
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of ctx which carries sc.
+func ContextWithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanFromContext returns the SpanContext carried by ctx, if any.
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// TraceMiddleware returns a handler which parses the traceparent header of
+// incoming requests and adds the resulting SpanContext to the request context,
+// before calling next. Requests without a valid header are passed on as is.
+func TraceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get("traceparent"); header != "" {
+			if sc, err := ParseTraceparent(header); err == nil {
+				r = r.WithContext(ContextWithSpan(r.Context(), sc))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// traceFields adds the "trace_id", "span_id" and "trace_flags" fields to log
+// entries made with a context carrying a SpanContext.
+func traceFields(ctx context.Context) map[string]interface{} {
+	sc, ok := SpanFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return map[string]interface{}{
+		"trace_id":    sc.TraceIDString(),
+		"span_id":     sc.SpanIDString(),
+		"trace_flags": sc.FlagsString(),
+	}
+}
+
+func init() {
+	RegisterContextExtractor(traceFields)
+}